@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ttacon/chalk"
+)
+
+// Diagnostic is a value that could not be decoded, such as an unknown ID or a
+// table revision without a layout. Diagnostics are collected while a bios is
+// shown and listed at the end so they can be reported.
+type Diagnostic struct {
+	Source string
+	Value  string
+}
+
+func (d Diagnostic) String() string {
+	return d.Source + ": " + d.Value
+}
+
+var diagnostics []Diagnostic
+
+// collectingDiagnostics is set once a command resets the diagnostics to list
+// them at the end, warnings then wait for the list instead of being printed.
+var collectingDiagnostics bool
+
+func addDiagnostic(source string, format string, args ...interface{}) {
+	diagnostics = append(diagnostics, Diagnostic{source, fmt.Sprintf(format, args...)})
+}
+
+func resetDiagnostics() {
+	diagnostics = nil
+	collectingDiagnostics = true
+}
+
+func displayDiagnostics() {
+	if len(diagnostics) == 0 {
+		return
+	}
+	fmt.Println(chalk.Yellow, "Detected unsupported data. Please report your results and GPU model so we can add it.", chalk.Reset)
+	for _, diagnostic := range diagnostics {
+		fmt.Println(chalk.Yellow, "\t"+diagnostic.String(), chalk.Reset)
+	}
+}
@@ -10,13 +10,19 @@ func displayRomVendorId(field uint16) string {
 	case 0x1002:
 		return "AMD"
 	default:
-		hasUnknownIds = true
+		addDiagnostic("ROM vendor ID", "0x%x", field)
 		return fmt.Sprintf("0x%x", field)
 	}
 }
 
 func displayRomDeviceId(field uint16) string {
 	switch field {
+	case 0x6920, 0x6921, 0x6928, 0x6929, 0x692b, 0x692f, 0x6930, 0x6938, 0x6939:
+		return "Tonga core (Tonga family)"
+	case 0x7300:
+		return "Fiji core (Fiji family)"
+	case 0x730f:
+		return "Fiji core (Fiji family)"
 	case 0x67c0:
 		return "Ellesmere core (Polaris 10 family)"
 	case 0x67df:
@@ -60,7 +66,7 @@ func displayRomDeviceId(field uint16) string {
 	case 0x699F:
 		return "Unknown (Polaris 12 family)"
 	default:
-		hasUnknownIds = true
+		addDiagnostic("ROM device ID", "0x%x", field)
 		return fmt.Sprintf("0x%x", field)
 	}
 }
@@ -73,7 +79,7 @@ func displaySubVendorId(field uint16) string {
 
 	doc, err := goquery.NewDocument(url)
 	if err != nil {
-		hasUnknownIds = true
+		addDiagnostic("Sub vendor ID", "0x%x, lookup failed: %s", field, err)
 		return fmt.Sprintf("0x%x", field)
 	}
 
@@ -105,7 +111,7 @@ func displaySubVendorId(field uint16) string {
 	})
 
 	if subVendorName == "" {
-		hasUnknownIds = true
+		addDiagnostic("Sub vendor ID", "0x%x", field)
 		return fmt.Sprintf("0x%x", field)
 	}
 
@@ -113,25 +119,29 @@ func displaySubVendorId(field uint16) string {
 }
 
 
+// displayVramVendorId shows the vendor in the low nibble, the high nibble is
+// the vendor revision.
 func displayVramVendorId(field byte) string {
 	id := field & 0x0F
 
 	value, found := vramVendors[id]
 	if !found {
-		hasUnknownIds = true
-		return fmt.Sprintf("%d", field)
+		addDiagnostic("VRAM vendor ID", "0x%x", id)
+		return fmt.Sprintf("%d", id)
 	}
 	return value
 
 }
 
-func displayVramDensity(field byte) string {
-	id := field & 0x0F
+func displayVramVendorRevision(field byte) string {
+	return fmt.Sprintf("%d", field >> 4)
+}
 
-	value, found := vramDensity[id]
+func displayVramDensity(field byte) string {
+	value, found := vramDensity[field]
 	if !found {
-		hasUnknownIds = true
-		return fmt.Sprintf("%d", field)
+		addDiagnostic("VRAM density", "0x%x", field)
+		return fmt.Sprintf("0x%x", field)
 	}
 	return value
 
@@ -142,9 +152,9 @@ func displayVramType(field byte) string {
 
 	value, found := vramType[id]
 	if !found {
-		hasUnknownIds = true
-		return fmt.Sprintf("%d", field)
+		addDiagnostic("VRAM type", "0x%x", field)
+		return fmt.Sprintf("0x%x", field)
 	}
 	return value
 
-}
\ No newline at end of file
+}
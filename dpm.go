@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/ttacon/chalk"
+)
+
+// AtomVirtualVoltageID0 is the first of the eight virtual voltage IDs, the
+// voltage of such a level is determined per chip from its leakage (EVV).
+const (
+	AtomVirtualVoltageID0 = 0xff01
+	AtomVirtualVoltageIDs = 8
+)
+
+// DPMLevel is a clock level of a dependency table with its voltage resolved
+// through the voltage lookup table. Voltage is 0 when the level uses a virtual
+// voltage or the lookup index is out of range.
+type DPMLevel struct {
+	Clock     uint32 // MHz
+	Voltage   uint16 // mV
+	VoltageID uint16
+}
+
+func isVirtualVoltage(vdd uint16) bool {
+	return vdd >= AtomVirtualVoltageID0 && vdd < AtomVirtualVoltageID0+AtomVirtualVoltageIDs
+}
+
+// lookupVoltage resolves a voltage lookup index of the v7 or v8 tables.
+func lookupVoltage(bios Bios, index byte) (uint16, bool) {
+	var vdd uint16
+	if bios.Family == FamilyVega10 {
+		if int(index) >= len(bios.AtomVega10VddcTable.Entries) {
+			return 0, false
+		}
+		vdd = bios.AtomVega10VddcTable.Entries[index].Vdd
+	} else {
+		if int(index) >= len(bios.AtomVoltageTable.Entries) {
+			return 0, false
+		}
+		vdd = bios.AtomVoltageTable.Entries[index].Vdd
+	}
+	return vdd, true
+}
+
+func dpmLevel(bios Bios, clock uint32, index byte) DPMLevel {
+	level := DPMLevel{Clock: clock / 100}
+	if vdd, found := lookupVoltage(bios, index); found {
+		level.VoltageID = vdd
+		if !isVirtualVoltage(vdd) {
+			level.Voltage = vdd
+		}
+	}
+	return level
+}
+
+// sclkLevels returns the GPU clock levels, gfxclk on Vega 10.
+func sclkLevels(bios Bios) []DPMLevel {
+	levels := []DPMLevel{}
+	if bios.Family == FamilyVega10 {
+		for _, entry := range bios.AtomVega10GfxClkTable.Entries {
+			levels = append(levels, dpmLevel(bios, entry.Clk, entry.VddInd))
+		}
+		return levels
+	}
+	for _, entry := range bios.AtomSClkTable.Entries {
+		levels = append(levels, dpmLevel(bios, entry.Sclk, entry.VddInd))
+	}
+	return levels
+}
+
+// mclkLevels returns the memory clock levels.
+func mclkLevels(bios Bios) []DPMLevel {
+	levels := []DPMLevel{}
+	if bios.Family == FamilyVega10 {
+		for _, entry := range bios.AtomVega10MClkTable.Entries {
+			levels = append(levels, dpmLevel(bios, entry.MemClk, entry.VddInd))
+		}
+		return levels
+	}
+	for _, entry := range bios.AtomMClkTable.Entries {
+		levels = append(levels, dpmLevel(bios, entry.Mclk, entry.VddcInd))
+	}
+	return levels
+}
+
+// powerLimit returns the default power limit the driver derives from the
+// tables, in W.
+func powerLimit(bios Bios) uint32 {
+	if bios.Family == FamilyVega10 {
+		return uint32(bios.AtomVega10PowertuneTable.SocketPowerLimit)
+	}
+	return uint32(bios.AtomPowertuneTable.MaximumPowerDeliveryLimit)
+}
+
+// maxPowerLimit returns the highest power limit allowed by the power control
+// limit, in W.
+func maxPowerLimit(bios Bios) uint32 {
+	return powerLimit(bios) * (100 + uint32(bios.AtomPowerplayTable.PowerControlLimit)) / 100
+}
+
+// fanMaxRPM returns the maximum fan speed of the fan table.
+func fanMaxRPM(bios Bios) uint16 {
+	if bios.Family == FamilyVega10 {
+		return bios.AtomVega10FanTable.FanAcousticLimitRpm
+	}
+	return bios.AtomFanTable.FanRPMMax
+}
+
+// dpmFlag is a dpm set flag, it takes level=value pairs. Vega 10 keeps the
+// clocks in other tables and fields, a flag without them is not supported
+// there.
+type dpmFlag struct {
+	Name      string
+	Help      string
+	Table     string
+	Field     string
+	VegaTable string
+	VegaField string
+	Scale     float64
+}
+
+var dpmFields = []dpmFlag{
+	{"sclk", "GPU clock of a level in MHz, e.g. 7=1366.", "SclkDependency", "Sclk", "GfxclkDependency", "Clk", 100},
+	{"mclk", "Memory clock of a level in MHz, e.g. 2=2000.", "MclkDependency", "Mclk", "MclkDependency", "MemClk", 100},
+	{"vddc-index", "Voltage lookup index of a GPU clock level, e.g. 7=5.", "SclkDependency", "VddInd", "GfxclkDependency", "VddInd", 1},
+	{"mclk-vddc-index", "Voltage lookup index of a memory clock level, e.g. 2=3.", "MclkDependency", "VddcInd", "MclkDependency", "VddInd", 1},
+	{"vddci", "VDDCI of a memory clock level in mV, e.g. 2=950.", "MclkDependency", "Vddci", "", "", 1},
+	{"mvdd", "MVDD of a memory clock level in mV, e.g. 2=1500.", "MclkDependency", "Mvdd", "", "", 1},
+}
+
+type dpmFlagSet struct {
+	flags  []dpmFlag
+	values []*map[string]string
+}
+
+func registerDPMFlags(cmd *kingpin.CmdClause, flags []dpmFlag) *dpmFlagSet {
+	set := &dpmFlagSet{flags: flags}
+	for _, flag := range flags {
+		set.values = append(set.values, cmd.Flag(flag.Name, flag.Help).StringMap())
+	}
+	return set
+}
+
+// Edits returns the edits of the level=value pairs, scaled to table units.
+func (s *dpmFlagSet) Edits(bios Bios) ([]FieldEdit, error) {
+	edits := []FieldEdit{}
+	for i, flag := range s.flags {
+		values := *s.values[i]
+		if len(values) == 0 {
+			continue
+		}
+		table, field := flag.Table, flag.Field
+		if bios.Family == FamilyVega10 {
+			if flag.VegaField == "" {
+				return nil, fmt.Errorf("--%s is not supported on Vega 10", flag.Name)
+			}
+			table, field = flag.VegaTable, flag.VegaField
+		}
+		levels := make([]string, 0, len(values))
+		for level := range values {
+			levels = append(levels, level)
+		}
+		sort.Strings(levels)
+		for _, level := range levels {
+			index, err := strconv.Atoi(level)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid level %s for --%s", level, flag.Name)
+			}
+			value, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(values[level]), "mv"), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %s for --%s", values[level], flag.Name)
+			}
+			edits = append(edits, FieldEdit{table, fmt.Sprintf("Entries[%d].%s", index, field), scaleValue(value, flag.Scale)})
+		}
+	}
+	return edits, nil
+}
+
+// validateDPM checks that the clocks rise with the level and that every level
+// has a voltage. Clocks above the hard limit or the overdrive limit are only
+// warned about.
+func validateDPM(bios Bios) error {
+	sclkLimit := bios.AtomPowerplayTable.MaxODEngineClock / 100
+	mclkLimit := bios.AtomPowerplayTable.MaxODMemoryClock / 100
+	if bios.Family == FamilyVega10 {
+		sclkLimit = bios.AtomVega10PowerplayTable.MaxODEngineClock / 100
+		mclkLimit = bios.AtomVega10PowerplayTable.MaxODMemoryClock / 100
+	}
+	var sclkHardLimit, mclkHardLimit uint32
+	if len(bios.AtomHardLimitTable.Entries) > 0 {
+		sclkHardLimit = bios.AtomHardLimitTable.Entries[0].SclkLimit / 100
+		mclkHardLimit = bios.AtomHardLimitTable.Entries[0].MclkLimit / 100
+	}
+
+	checks := []struct {
+		name      string
+		levels    []DPMLevel
+		odLimit   uint32
+		hardLimit uint32
+	}{
+		{"GPU", sclkLevels(bios), sclkLimit, sclkHardLimit},
+		{"memory", mclkLevels(bios), mclkLimit, mclkHardLimit},
+	}
+	for _, check := range checks {
+		for i, level := range check.levels {
+			if i > 0 && level.Clock <= check.levels[i-1].Clock {
+				return fmt.Errorf("%s clocks must rise with the level, level %d has %d Mhz after %d Mhz",
+					check.name, i, level.Clock, check.levels[i-1].Clock)
+			}
+			if level.VoltageID == 0 {
+				return fmt.Errorf("%s level %d has a voltage lookup index out of range", check.name, i)
+			}
+			if check.hardLimit != 0 && level.Clock > check.hardLimit {
+				fmt.Fprintln(os.Stderr, chalk.Yellow, fmt.Sprintf("%s level %d of %d Mhz exceeds the hard limit of %d Mhz", check.name, i, level.Clock, check.hardLimit), chalk.Reset)
+			}
+			if check.odLimit != 0 && level.Clock > check.odLimit {
+				fmt.Fprintln(os.Stderr, chalk.Yellow, fmt.Sprintf("%s level %d of %d Mhz exceeds the overdrive limit of %d Mhz", check.name, i, level.Clock, check.odLimit), chalk.Reset)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/ttacon/chalk"
+)
+
+// Edits are given per table and field, either on the command line as
+// Table.Field=value or in a YAML file:
+//
+//	powertune:
+//	  TDP: 120
+//	sclk:
+//	  Entries[7].Sclk: 140000
+//
+// Only this two level subset of YAML is understood. Values are raw table
+// units, e.g. clocks in 10 kHz and fan temperatures in 0.01 C.
+
+type FieldEdit struct {
+	Table string
+	Field string
+	Value string
+}
+
+func (e FieldEdit) String() string {
+	return e.Table + "." + e.Field
+}
+
+// tableAliases maps the short names accepted in edits to the table names.
+var tableAliases = map[string]string{
+	"powerplay": "PowerPlayInfo",
+	"powertune": "PowerTuneTable",
+	"fan":       "FanTable",
+	"sclk":      "SclkDependency",
+	"mclk":      "MclkDependency",
+	"vddc":      "VddcLookup",
+	"gfxclk":    "GfxclkDependency",
+	"socclk":    "SocclkDependency",
+	"dcefclk":   "DcefclkDependency",
+	"memadjust": "MemAdjust",
+	"mcpertile": "McAdjustPerTile",
+	"mcphyinit": "McPhyInit",
+	"dramremap": "DramDataRemap",
+}
+
+// structuralFields change the layout of a table and can not be edited, neither
+// can the sub-table offsets.
+var structuralFields = map[string]bool{
+	"Header":     true,
+	"RevID":      true,
+	"NumEntries": true,
+	"Registers":  true,
+}
+
+func tableName(name string) string {
+	if table, found := tableAliases[strings.ToLower(name)]; found {
+		return table
+	}
+	for _, table := range tableAliases {
+		if strings.EqualFold(name, table) {
+			return table
+		}
+	}
+	return name
+}
+
+// parseFieldEdit parses an edit of the form Table.Field=value.
+func parseFieldEdit(edit string) (FieldEdit, error) {
+	assignment := strings.SplitN(edit, "=", 2)
+	path := strings.SplitN(assignment[0], ".", 2)
+	if len(assignment) != 2 || len(path) != 2 {
+		return FieldEdit{}, fmt.Errorf("invalid edit %q, expected Table.Field=value", edit)
+	}
+	return FieldEdit{
+		Table: tableName(strings.TrimSpace(path[0])),
+		Field: strings.TrimSpace(path[1]),
+		Value: strings.TrimSpace(assignment[1]),
+	}, nil
+}
+
+// loadEdits reads the edits from a YAML file.
+func loadEdits(filename string) ([]FieldEdit, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	edits := []FieldEdit{}
+	table := ""
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := scanner.Text()
+		if comment := strings.Index(line, "#"); comment >= 0 {
+			line = line[:comment]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indented := line[0] == ' ' || line[0] == '\t'
+		pair := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected key: value", filename, number)
+		}
+		key := strings.TrimSpace(pair[0])
+		value := strings.Trim(strings.TrimSpace(pair[1]), `"'`)
+
+		switch {
+		case !indented && value == "":
+			table = tableName(key)
+		case indented && table != "" && value != "":
+			edits = append(edits, FieldEdit{table, key, value})
+		case !indented && value != "":
+			edit, err := parseFieldEdit(key + "=" + value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %s", filename, number, err)
+			}
+			edits = append(edits, edit)
+		default:
+			return nil, fmt.Errorf("%s:%d: unexpected %q", filename, number, strings.TrimSpace(line))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+// collectEdits gathers the edits of an edits file followed by the edits given
+// on the command line, so the command line wins.
+func collectEdits(filename string, sets []string) ([]FieldEdit, error) {
+	edits := []FieldEdit{}
+	if filename != "" {
+		fileEdits, err := loadEdits(filename)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, fileEdits...)
+	}
+	for _, set := range sets {
+		edit, err := parseFieldEdit(set)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, edit)
+	}
+	return edits, nil
+}
+
+// editableTable returns the decoded structure that holds a table, the Vega 10
+// tables have their own structures.
+func editableTable(bios *Bios, table string) (interface{}, error) {
+	if _, found := bios.Tables[table]; !found {
+		return nil, fmt.Errorf("no %s table was decoded", table)
+	}
+	switch table {
+	case "MemAdjust":
+		return &bios.AtomMemAdjustTable, nil
+	case "McAdjustPerTile":
+		return &bios.AtomMcAdjustPerTileTable, nil
+	case "McPhyInit":
+		return &bios.AtomMcPhyInitTable, nil
+	case "DramDataRemap":
+		return &bios.AtomDramDataRemapTable, nil
+	}
+	if bios.Tables["PowerPlayInfo"].Revision.Format == 8 {
+		switch table {
+		case "PowerPlayInfo":
+			return &bios.AtomVega10PowerplayTable, nil
+		case "PowerTuneTable":
+			return &bios.AtomVega10PowertuneTable, nil
+		case "FanTable":
+			return &bios.AtomVega10FanTable, nil
+		case "GfxclkDependency":
+			return &bios.AtomVega10GfxClkTable, nil
+		case "SocclkDependency":
+			return &bios.AtomVega10SocClkTable, nil
+		case "DcefclkDependency":
+			return &bios.AtomVega10DcefClkTable, nil
+		case "MclkDependency":
+			return &bios.AtomVega10MClkTable, nil
+		case "VddcLookup":
+			return &bios.AtomVega10VddcTable, nil
+		}
+	} else {
+		switch table {
+		case "PowerPlayInfo":
+			return &bios.AtomPowerplayTable, nil
+		case "PowerTuneTable":
+			return &bios.AtomPowertuneTable, nil
+		case "FanTable":
+			return &bios.AtomFanTable, nil
+		case "SclkDependency":
+			return &bios.AtomSClkTable, nil
+		case "MclkDependency":
+			return &bios.AtomMClkTable, nil
+		case "VddcLookup":
+			return &bios.AtomVoltageTable, nil
+		}
+	}
+	return nil, fmt.Errorf("table %s can not be edited", table)
+}
+
+// EditChange records the value of an edited field before and after the edit.
+type EditChange struct {
+	Edit     FieldEdit
+	Previous interface{}
+	Value    interface{}
+}
+
+func (c EditChange) String() string {
+	return fmt.Sprintf("%s: %v -> %v", c.Edit, c.Previous, c.Value)
+}
+
+// applyEdits sets the edited fields in the decoded tables.
+func applyEdits(bios *Bios, edits []FieldEdit) ([]EditChange, error) {
+	changes := []EditChange{}
+	for _, edit := range edits {
+		table, err := editableTable(bios, edit.Table)
+		if err != nil {
+			return nil, err
+		}
+		field, err := lookupField(reflect.ValueOf(table).Elem(), edit.Field)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", edit, err)
+		}
+		previous := field.Interface()
+		if err := setField(field, edit.Value); err != nil {
+			return nil, fmt.Errorf("%s: %s", edit, err)
+		}
+		changes = append(changes, EditChange{edit, previous, field.Interface()})
+	}
+	return changes, nil
+}
+
+// changedTables returns the tables touched by the changes, in the order they
+// were first edited.
+func changedTables(changes []EditChange) []string {
+	tables := []string{}
+	for _, change := range changes {
+		if !containsString(tables, change.Edit.Table) {
+			tables = append(tables, change.Edit.Table)
+		}
+	}
+	return tables
+}
+
+// tableValidators check the tables that edits can break, the validator of a
+// table runs after every edit of it.
+var tableValidators = []struct {
+	tables   []string
+	validate func(bios Bios) error
+}{
+	{[]string{"FanTable"}, validateFan},
+	{[]string{"PowerTuneTable"}, validatePowertuneLimits},
+	{[]string{"PowerPlayInfo"}, validatePowerplay},
+	{[]string{"SclkDependency", "GfxclkDependency", "MclkDependency", "VddcLookup"}, validateDPM},
+}
+
+// validateEdits runs the validators of the tables touched by the changes.
+func validateEdits(bios Bios, changes []EditChange) error {
+	tables := changedTables(changes)
+	for _, validator := range tableValidators {
+		for _, table := range validator.tables {
+			if containsString(tables, table) {
+				if err := validator.validate(bios); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func displayChanges(changes []EditChange) {
+	for _, change := range changes {
+		fmt.Printf("%s%s: %s%v -> %v%s\n", chalk.Bold, change.Edit, chalk.White, change.Previous, change.Value, chalk.Reset)
+	}
+}
+
+// lookupField resolves a field path such as Entries[7].Sclk.
+func lookupField(value reflect.Value, path string) (reflect.Value, error) {
+	for _, name := range strings.Split(path, ".") {
+		index := -1
+		if open := strings.Index(name, "["); open >= 0 && strings.HasSuffix(name, "]") {
+			parsed, err := strconv.Atoi(name[open+1 : len(name)-1])
+			if err != nil {
+				return value, fmt.Errorf("invalid index in %s", name)
+			}
+			name, index = name[:open], parsed
+		}
+		if name == "_" || structuralFields[name] || strings.HasSuffix(name, "TableOffset") {
+			return value, fmt.Errorf("field %s can not be edited", name)
+		}
+		if value.Kind() != reflect.Struct {
+			return value, fmt.Errorf("%s is not a table field", name)
+		}
+		value = value.FieldByName(name)
+		if !value.IsValid() {
+			return value, fmt.Errorf("unknown field %s", name)
+		}
+		if index >= 0 {
+			if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+				return value, fmt.Errorf("field %s is not a list", name)
+			}
+			if index >= value.Len() {
+				return value, fmt.Errorf("index %d of %s is out of range, it has %d entries", index, name, value.Len())
+			}
+			value = value.Index(index)
+		}
+	}
+	return value, nil
+}
+
+// setField parses the value for the kind of the field and refuses values that
+// do not fit.
+func setField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 0, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid value %s for a %d bit field", value, field.Type().Bits())
+		}
+		field.SetUint(parsed)
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 0, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid value %s for a signed %d bit field", value, field.Type().Bits())
+		}
+		field.SetInt(parsed)
+	default:
+		return fmt.Errorf("only numeric fields can be edited")
+	}
+	return nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldFlag is a command line flag that edits a table field. Scale converts
+// the value from the unit of the flag to the unit of the table, e.g. 100 for
+// temperatures in C stored in 0.01 C.
+type fieldFlag struct {
+	Name  string
+	Field string
+	Scale float64
+	Help  string
+}
+
+// fieldFlagSet holds the values of the field flags of a command.
+type fieldFlagSet struct {
+	table  string
+	flags  []fieldFlag
+	values []*string
+}
+
+func registerFieldFlags(cmd *kingpin.CmdClause, table string, flags []fieldFlag) *fieldFlagSet {
+	set := &fieldFlagSet{table: table, flags: flags}
+	for _, flag := range flags {
+		set.values = append(set.values, cmd.Flag(flag.Name, flag.Help).String())
+	}
+	return set
+}
+
+// editFlags are the flags of a set command, they turn into edits of the
+// decoded tables.
+type editFlags interface {
+	Edits(bios Bios) ([]FieldEdit, error)
+}
+
+// Edits returns the edits of the flags that were given, scaled to table units.
+func (s *fieldFlagSet) Edits(bios Bios) ([]FieldEdit, error) {
+	edits := []FieldEdit{}
+	for i, flag := range s.flags {
+		value := *s.values[i]
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %s for --%s", value, flag.Name)
+		}
+		edits = append(edits, FieldEdit{s.table, flag.Field, scaleValue(parsed, flag.Scale)})
+	}
+	return edits, nil
+}
+
+// scaleValue converts a value to table units, rounded to the nearest integer.
+func scaleValue(value float64, scale float64) string {
+	return strconv.FormatInt(int64(math.Floor(value*scale+0.5)), 10)
+}
+
+// setFields applies the edits of the flags to a file, validates the result and
+// writes it to out.
+func setFields(filename string, out string, flags editFlags, validate func(bios Bios) error) {
+	buffer := readFile(filename)
+	bios := openTables(buffer)
+
+	edits, err := flags.Edits(bios)
+	if err == nil && len(edits) == 0 {
+		err = fmt.Errorf("no values given")
+	}
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	changes, err := applyEdits(&bios, edits)
+	if err == nil {
+		err = validate(bios)
+	}
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+
+	if err := saveTables(out, buffer, &bios, changedTables(changes)); err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	displayChanges(changes)
+}
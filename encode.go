@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+
+	"gopkg.in/restruct.v1"
+)
+
+// Tables are written back through encoders registered per table and layout
+// name, the same layout that decoded the table is used to pack it. Only the
+// bytes that differ from the packed original are written, reserved and
+// undecoded bytes keep their value from the image.
+//
+// Layouts without an encoder are read only.
+
+type tableEncoder func(buffer []byte, offset int, bios *Bios) error
+
+var tableEncoders = map[string]map[string]tableEncoder{}
+
+func registerEncoder(table string, layout string, encode tableEncoder) {
+	if tableEncoders[table] == nil {
+		tableEncoders[table] = map[string]tableEncoder{}
+	}
+	tableEncoders[table][layout] = encode
+}
+
+func init() {
+	registerEncoder("PowerPlayInfo", "Tonga", encodeTongaPowerplay)
+	registerEncoder("PowerTuneTable", "Tonga", encodeTongaPowertune)
+	registerEncoder("PowerTuneTable", "Fiji", encodeFijiPowertune)
+	registerEncoder("PowerTuneTable", "Polaris", encodeFijiPowertune)
+	registerEncoder("FanTable", "Tonga", encodeTongaFan)
+	registerEncoder("FanTable", "Fiji", encodeFijiFan)
+	registerEncoder("FanTable", "Polaris", encodeFijiFan)
+	registerEncoder("SclkDependency", "Tonga", encodeTongaSclk)
+	registerEncoder("SclkDependency", "Polaris", encodePolarisSclk)
+	registerEncoder("MclkDependency", "Tonga", encodeTongaMclk)
+	registerEncoder("VddcLookup", "Tonga", encodeTongaVoltageLookup)
+	registerEncoder("MMDependency", "Tonga", encodeTongaMMDependency)
+	registerEncoder("PowerPlayInfo", "Vega 10", encodeVega10Powerplay)
+	registerEncoder("GfxclkDependency", "Vega 10 V2", encodeVega10GfxClk)
+	registerEncoder("SocclkDependency", "Vega 10", encodeVega10SocClk)
+	registerEncoder("DcefclkDependency", "Vega 10", encodeVega10DcefClk)
+	registerEncoder("MclkDependency", "Vega 10", encodeVega10MClk)
+	registerEncoder("VddcLookup", "Vega 10", encodeVega10VoltageLookup)
+	registerEncoder("FanTable", "Vega 10 V2", encodeVega10Fan)
+	registerEncoder("PowerTuneTable", "Vega 10 V2", encodeVega10Powertune)
+	registerEncoder("MemAdjust", "Init Reg Block", encodeMemAdjust)
+	registerEncoder("McAdjustPerTile", "Init Reg Block", encodeMcAdjustPerTile)
+	registerEncoder("McPhyInit", "Init Reg Block", encodeMcPhyInit)
+	registerEncoder("DramDataRemap", "V2", encodeDramDataRemap)
+	registerEncoder("MemClkPatch", "Strap", encodeMemClkPatch)
+}
+
+// encodeTable packs the decoded table back into the buffer at the offset it
+// was decoded from.
+func encodeTable(table string, buffer []byte, bios *Bios) error {
+	location, found := bios.Tables[table]
+	if !found {
+		return fmt.Errorf("no %s table was decoded", table)
+	}
+	encode, found := tableEncoders[table][location.Layout]
+	if !found {
+		return fmt.Errorf("writing the %s layout of %s is not supported", location.Layout, table)
+	}
+	if err := encode(buffer, location.Offset, bios); err != nil {
+		return fmt.Errorf("error encoding %s revision %s: %s", table, location.Revision, err)
+	}
+	return nil
+}
+
+// packAt packs object into the buffer at offset, writing only the bytes that
+// changed. Object must be a pointer to the structure the table was decoded
+// with.
+func packAt(buffer []byte, offset int, object interface{}) error {
+	data, err := restruct.Pack(binary.LittleEndian, object)
+	if err != nil {
+		return err
+	}
+	if offset < 0 || offset+len(data) > len(buffer) {
+		return fmt.Errorf("table at 0x%x does not fit in the image", offset)
+	}
+	original := reflect.New(reflect.TypeOf(object).Elem()).Interface()
+	if err := unpackAt(buffer, offset, original); err != nil {
+		return err
+	}
+	originalData, err := restruct.Pack(binary.LittleEndian, original)
+	if err != nil {
+		return err
+	}
+	if len(originalData) != len(data) {
+		return fmt.Errorf("table size changed from %d to %d bytes", len(originalData), len(data))
+	}
+	for i := range data {
+		if data[i] != originalData[i] {
+			buffer[offset+i] = data[i]
+		}
+	}
+	return nil
+}
+
+// copyFields copies the fields of src to the fields of dst with the same name
+// and type, used to convert between the generic and the older layouts. A field
+// that the older layout lacks must still be zero, otherwise it was edited.
+func copyFields(dst interface{}, src interface{}) error {
+	dstValue := reflect.ValueOf(dst).Elem()
+	srcValue := reflect.ValueOf(src).Elem()
+	for i := 0; i < srcValue.NumField(); i++ {
+		field := srcValue.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		dstField := dstValue.FieldByName(field.Name)
+		if !dstField.IsValid() || dstField.Type() != field.Type {
+			if reflect.DeepEqual(srcValue.Field(i).Interface(), reflect.Zero(field.Type).Interface()) {
+				continue
+			}
+			return fmt.Errorf("%s is not part of this layout", field.Name)
+		}
+		dstField.Set(srcValue.Field(i))
+	}
+	return nil
+}
+
+func encodeTongaPowerplay(buffer []byte, offset int, bios *Bios) error {
+	return packAt(buffer, offset, &bios.AtomPowerplayTable)
+}
+
+func encodeTongaPowertune(buffer []byte, offset int, bios *Bios) error {
+	table := AtomTongaPowertuneTable{}
+	if err := unpackAt(buffer, offset, &table); err != nil {
+		return err
+	}
+	if err := copyFields(&table, &bios.AtomPowertuneTable); err != nil {
+		return err
+	}
+	return packAt(buffer, offset, &table)
+}
+
+func encodeTongaFan(buffer []byte, offset int, bios *Bios) error {
+	table := AtomTongaFanTable{}
+	if err := unpackAt(buffer, offset, &table); err != nil {
+		return err
+	}
+	if err := copyFields(&table, &bios.AtomFanTable); err != nil {
+		return err
+	}
+	return packAt(buffer, offset, &table)
+}
+
+func encodeFijiPowertune(buffer []byte, offset int, bios *Bios) error {
+	return packAt(buffer, offset, &bios.AtomPowertuneTable)
+}
+
+func encodeFijiFan(buffer []byte, offset int, bios *Bios) error {
+	return packAt(buffer, offset, &bios.AtomFanTable)
+}
+
+func encodeTongaSclk(buffer []byte, offset int, bios *Bios) error {
+	table := AtomTongaSClkTable{}
+	if err := unpackAt(buffer, offset, &table); err != nil {
+		return err
+	}
+	if len(table.Entries) != len(bios.AtomSClkTable.Entries) {
+		return fmt.Errorf("the number of entries can not be changed")
+	}
+	for i := range table.Entries {
+		if err := copyFields(&table.Entries[i], &bios.AtomSClkTable.Entries[i]); err != nil {
+			return err
+		}
+	}
+	return packAt(buffer, offset, &table)
+}
+
+func encodePolarisSclk(buffer []byte, offset int, bios *Bios) error {
+	return packAt(buffer, offset, &bios.AtomSClkTable)
+}
+
+func encodeTongaMclk(buffer []byte, offset int, bios *Bios) error {
+	return packAt(buffer, offset, &bios.AtomMClkTable)
+}
+
+func encodeTongaVoltageLookup(buffer []byte, offset int, bios *Bios) error {
+	return packAt(buffer, offset, &bios.AtomVoltageTable)
+}
+
+func encodeTongaMMDependency(buffer []byte, offset int, bios *Bios) error {
+	return packAt(buffer, offset, &bios.AtomMMDependencyTable)
+}
+
+func encodeVega10Powerplay(buffer []byte, offset int, bios *Bios) error {
+	return packAt(buffer, offset, &bios.AtomVega10PowerplayTable)
+}
+
+func encodeVega10GfxClk(buffer []byte, offset int, bios *Bios) error {
+	return packAt(buffer, offset, &bios.AtomVega10GfxClkTable)
+}
+
+func encodeVega10SocClk(buffer []byte, offset int, bios *Bios) error {
+	return packAt(buffer, offset, &bios.AtomVega10SocClkTable)
+}
+
+func encodeVega10DcefClk(buffer []byte, offset int, bios *Bios) error {
+	return packAt(buffer, offset, &bios.AtomVega10DcefClkTable)
+}
+
+func encodeVega10MClk(buffer []byte, offset int, bios *Bios) error {
+	return packAt(buffer, offset, &bios.AtomVega10MClkTable)
+}
+
+func encodeVega10VoltageLookup(buffer []byte, offset int, bios *Bios) error {
+	return packAt(buffer, offset, &bios.AtomVega10VddcTable)
+}
+
+func encodeVega10Fan(buffer []byte, offset int, bios *Bios) error {
+	return packAt(buffer, offset, &bios.AtomVega10FanTable)
+}
+
+func encodeVega10Powertune(buffer []byte, offset int, bios *Bios) error {
+	return packAt(buffer, offset, &bios.AtomVega10PowertuneTable)
+}
+
+// encodeInitRegBlock writes the MemoryID and register values of the data
+// blocks. A register that repeats the previous value has no data of its own,
+// it can only follow the register before it.
+func encodeInitRegBlock(buffer []byte, offset int, block *AtomInitRegBlock) error {
+	original := AtomInitRegBlock{}
+	if err := decodeInitRegBlock(buffer, offset, &original); err != nil {
+		return err
+	}
+	if len(block.Blocks) != len(original.Blocks) {
+		return fmt.Errorf("the number of data blocks can not be changed")
+	}
+	header := AtomInitRegBlockHeader{}
+	if err := unpackAt(buffer, offset, &header); err != nil {
+		return err
+	}
+	dataOffset := offset + 4 + int(header.RegIndexTblSize)
+	for i, data := range block.Blocks {
+		if len(data.Data) != len(original.Registers) {
+			return fmt.Errorf("the number of registers can not be changed")
+		}
+		if data.MemoryID == AtomEndOfRegDataBlock {
+			return fmt.Errorf("MemoryID of data block %d can not be 0, it ends the table", i)
+		}
+		binary.LittleEndian.PutUint32(buffer[dataOffset:], data.MemoryID)
+		value := dataOffset + 4
+		for r, register := range original.Registers {
+			if register.PreRegDataLength&AtomRegDataLengthMask == AtomRegDataSameAsPrevious {
+				if r > 0 && data.Data[r] != original.Blocks[i].Data[r] && data.Data[r] != data.Data[r-1] {
+					return fmt.Errorf("register %s of data block %d repeats the previous register and can not be set on its own",
+						mcRegisterName(register), i)
+				}
+				continue
+			}
+			binary.LittleEndian.PutUint32(buffer[value:], data.Data[r])
+			value += 4
+		}
+		dataOffset += int(header.RegDataBlkSize)
+	}
+	return nil
+}
+
+func encodeMemAdjust(buffer []byte, offset int, bios *Bios) error {
+	return encodeInitRegBlock(buffer, offset, &bios.AtomMemAdjustTable)
+}
+
+func encodeMcAdjustPerTile(buffer []byte, offset int, bios *Bios) error {
+	return encodeInitRegBlock(buffer, offset, &bios.AtomMcAdjustPerTileTable)
+}
+
+func encodeMcPhyInit(buffer []byte, offset int, bios *Bios) error {
+	return encodeInitRegBlock(buffer, offset, &bios.AtomMcPhyInitTable)
+}
+
+func encodeDramDataRemap(buffer []byte, offset int, bios *Bios) error {
+	for i := range bios.AtomDramDataRemapTable.Entries {
+		if err := packAt(buffer, offset+i*AtomDramDataRemapSize, &bios.AtomDramDataRemapTable.Entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeMemClkPatch writes the straps in place. Fewer straps than decoded end
+// the table early, the freed straps are zeroed so the table keeps its size.
+func encodeMemClkPatch(buffer []byte, offset int, bios *Bios) error {
+	header := AtomInitRegBlockHeader{}
+	if err := unpackAt(buffer, offset, &header); err != nil {
+		return err
+	}
+	original := Bios{}
+	if err := decodeMemClkPatch(buffer, offset, &original); err != nil {
+		return err
+	}
+	if len(bios.AtomVRAMTimingEntry) > len(original.AtomVRAMTimingEntry) {
+		return fmt.Errorf("straps can not be added, the table has room for %d", len(original.AtomVRAMTimingEntry))
+	}
+	entryOffset := offset + 4 + int(header.RegIndexTblSize)
+	for i := range bios.AtomVRAMTimingEntry {
+		if bios.AtomVRAMTimingEntry[i].ClkRange == AtomEndOfRegDataBlock {
+			return fmt.Errorf("ClkRange of strap %d can not be 0, it ends the table", i)
+		}
+		if err := packAt(buffer, entryOffset+i*AtomVRAMTimingEntrySize, &bios.AtomVRAMTimingEntry[i]); err != nil {
+			return err
+		}
+	}
+	end := entryOffset + len(original.AtomVRAMTimingEntry)*AtomVRAMTimingEntrySize
+	for i := entryOffset + len(bios.AtomVRAMTimingEntry)*AtomVRAMTimingEntrySize; i < end; i++ {
+		buffer[i] = 0
+	}
+	return nil
+}
+
+// saveTables encodes the changed tables and writes the image. ROM images get
+// their checksum fixed, PowerPlay tables have none.
+func saveTables(filename string, buffer []byte, bios *Bios, tables []string) error {
+	for _, table := range tables {
+		if err := encodeTable(table, buffer, bios); err != nil {
+			return err
+		}
+	}
+	if !isPowerplayBlob(buffer) {
+		warnRomSize(buffer)
+		fixChecksum(buffer)
+	}
+	return ioutil.WriteFile(filename, buffer, 0644)
+}
+
+// fixChecksum updates the checksum byte so the bytes of the ROM image add up
+// to zero. The image size is given in 512 byte blocks at offset 2.
+func fixChecksum(buffer []byte) {
+	size := int(buffer[2]) * 512
+	if size == 0 || size > len(buffer) {
+		size = len(buffer)
+	}
+	var sum byte
+	for _, value := range buffer[:size] {
+		sum += value
+	}
+	buffer[ROM_CHECKSUM_OFFSET] -= sum
+}
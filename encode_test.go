@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// memClkPatch builds a strap table at offset 0 followed by a byte pattern
+// that stands for the tables after it.
+func memClkPatch(straps []AtomVRAMTimingEntry) []byte {
+	buffer := &bytes.Buffer{}
+	registers := polarisRegisters()
+	binary.Write(buffer, binary.LittleEndian, AtomInitRegBlockHeader{uint16(3 * (len(registers) + 1)), AtomVRAMTimingEntrySize})
+	for _, register := range registers {
+		binary.Write(buffer, binary.LittleEndian, register)
+	}
+	binary.Write(buffer, binary.LittleEndian, AtomInitRegIndex{AtomEndOfRegIndexBlock, AtomRegAccessPlaceholder})
+	for _, strap := range straps {
+		binary.Write(buffer, binary.LittleEndian, strap)
+	}
+	binary.Write(buffer, binary.LittleEndian, uint32(AtomEndOfRegDataBlock))
+	buffer.Write(bytes.Repeat([]byte{0xee}, 16))
+	return buffer.Bytes()
+}
+
+func TestEncodeMemClkPatch(t *testing.T) {
+	straps := []AtomVRAMTimingEntry{
+		testStrap(0, 1000, 1),
+		testStrap(0, 1500, 1),
+		testStrap(0, 2000, 2),
+	}
+	buffer := memClkPatch(straps)
+	stock := append([]byte{}, buffer...)
+
+	bios := Bios{}
+	if err := decodeMemClkPatch(buffer, 0, &bios); err != nil {
+		t.Fatal(err)
+	}
+	if err := encodeMemClkPatch(buffer, 0, &bios); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buffer, stock) {
+		t.Error("encoding the decoded straps changed the table")
+	}
+
+	// A merged table ends early and keeps its size, the freed strap is zeroed
+	// and the tables after it stay in place.
+	bios.AtomVRAMTimingEntry = bios.AtomVRAMTimingEntry[1:]
+	if err := encodeMemClkPatch(buffer, 0, &bios); err != nil {
+		t.Fatal(err)
+	}
+	if len(buffer) != len(stock) {
+		t.Fatalf("table is %d bytes, expected %d", len(buffer), len(stock))
+	}
+	merged := memClkPatch(straps[1:])
+	expected := append(merged[:len(merged)-16], make([]byte, AtomVRAMTimingEntrySize)...)
+	expected = append(expected, stock[len(stock)-16:]...)
+	if !bytes.Equal(buffer, expected) {
+		t.Errorf("merged table is\n%x, expected\n%x", buffer, expected)
+	}
+	decoded := Bios{}
+	if err := decodeMemClkPatch(buffer, 0, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.AtomVRAMTimingEntry) != 2 || decoded.AtomVRAMTimingEntry[0] != straps[1] || decoded.AtomVRAMTimingEntry[1] != straps[2] {
+		t.Errorf("merged table decodes as %v", decoded.AtomVRAMTimingEntry)
+	}
+
+	bios.AtomVRAMTimingEntry = append(straps, testStrap(0, 2250, 2))
+	if err := encodeMemClkPatch(buffer, 0, &bios); err == nil {
+		t.Error("a strap was added to the table")
+	}
+	bios.AtomVRAMTimingEntry = []AtomVRAMTimingEntry{{}}
+	if err := encodeMemClkPatch(buffer, 0, &bios); err == nil {
+		t.Error("a strap with a ClkRange of 0 was written")
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+const (
+	FamilyUnknown = "Unknown"
+	FamilyTonga   = "Tonga"
+	FamilyFiji    = "Fiji"
+	FamilyPolaris = "Polaris"
+	FamilyVega10  = "Vega 10"
+)
+
+// detectFamily determines the GPU family from the PCIR device ID. ROMs with an
+// unknown device ID fall back to the PowerPlay revision and the layouts picked
+// for its sub-tables.
+func detectFamily(bios Bios) string {
+	id := bios.PCIRHeader.DeviceID
+	switch {
+	case id >= 0x6920 && id <= 0x693f:
+		return FamilyTonga
+	case id == 0x7300 || id == 0x730f:
+		return FamilyFiji
+	case id >= 0x67c0 && id <= 0x67ff, id >= 0x6980 && id <= 0x699f:
+		return FamilyPolaris
+	case id >= 0x6860 && id <= 0x687f:
+		return FamilyVega10
+	}
+
+	powerplay, found := bios.Tables["PowerPlayInfo"]
+	if !found {
+		return FamilyUnknown
+	}
+	switch {
+	case powerplay.Revision.Format == 8:
+		return FamilyVega10
+	case bios.Tables["SclkDependency"].Layout == "Polaris":
+		return FamilyPolaris
+	case bios.Tables["FanTable"].Layout == "Fiji":
+		return FamilyFiji
+	case bios.Tables["SclkDependency"].Layout == "Tonga":
+		return FamilyTonga
+	}
+	return FamilyUnknown
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+)
+
+// The fan table stores the curve temperatures and PWM points in 0.01 units,
+// the fan set flags take C and %.
+var fanFields = []fieldFlag{
+	{"thyst", "THyst", 1, "Temperature hysteresis in C."},
+	{"tmin", "TMin", 100, "Temperature of the minimum PWM point in C."},
+	{"tmed", "TMed", 100, "Temperature of the medium PWM point in C."},
+	{"thigh", "THigh", 100, "Temperature of the high PWM point in C."},
+	{"tmax", "TMax", 100, "Maximum temperature in C."},
+	{"pwm-min", "PWMMin", 100, "Minimum PWM in %."},
+	{"pwm-med", "PWMMed", 100, "Medium PWM in %."},
+	{"pwm-high", "PWMHigh", 100, "High PWM in %."},
+	{"pwm-max", "FanPWMMax", 100, "Maximum PWM in %."},
+	{"target-temp", "TargetTemperature", 1, "Target temperature in C."},
+	{"min-pwm-limit", "MinimumPWMLimit", 1, "Minimum PWM limit in %."},
+	{"rpm-max", "FanRPMMax", 1, "Maximum fan speed in RPM."},
+	{"control-mode", "FanControlMode", 1, "Fan control mode, 0 legacy or 1 fuzzy."},
+	{"gain-edge", "FanGainEdge", 1, "Fan gain of the edge temperature."},
+	{"gain-hotspot", "FanGainHotspot", 1, "Fan gain of the hotspot temperature."},
+	{"gain-liquid", "FanGainLiquid", 1, "Fan gain of the liquid temperature."},
+	{"gain-vr-vddc", "FanGainVrVddc", 1, "Fan gain of the VDDC regulator temperature."},
+	{"gain-vr-mvdd", "FanGainVrMvdd", 1, "Fan gain of the MVDD regulator temperature."},
+	{"gain-plx", "FanGainPlx", 1, "Fan gain of the PLX temperature."},
+	{"gain-hbm", "FanGainHbm", 1, "Fan gain of the HBM temperature."},
+}
+
+// validateFan checks that the fan curve rises, the temperatures strictly and
+// the PWM points at least stay level. The Vega 10 fan table has no curve.
+func validateFan(bios Bios) error {
+	if bios.Family == FamilyVega10 {
+		return nil
+	}
+	fan := bios.AtomFanTable
+	temperatures := []uint16{fan.TMin, fan.TMed, fan.THigh, fan.TMax}
+	for i := 1; i < len(temperatures); i++ {
+		if temperatures[i] <= temperatures[i-1] {
+			return fmt.Errorf("fan temperatures must rise, got %.2f, %.2f, %.2f and %.2f C",
+				float64(fan.TMin)/100, float64(fan.TMed)/100, float64(fan.THigh)/100, float64(fan.TMax)/100)
+		}
+	}
+	pwms := []uint16{fan.PWMMin, fan.PWMMed, fan.PWMHigh}
+	for i := 1; i < len(pwms); i++ {
+		if pwms[i] < pwms[i-1] {
+			return fmt.Errorf("fan PWM points must not fall, got %.2f, %.2f and %.2f %%",
+				float64(fan.PWMMin)/100, float64(fan.PWMMed)/100, float64(fan.PWMHigh)/100)
+		}
+	}
+	if fan.PWMHigh > 10000 {
+		return fmt.Errorf("fan PWM of %.2f %% is above 100 %%", float64(fan.PWMHigh)/100)
+	}
+	if fan.FanPWMMax > 10000 {
+		return fmt.Errorf("maximum fan PWM of %.2f %% is above 100 %%", float64(fan.FanPWMMax)/100)
+	}
+	return nil
+}
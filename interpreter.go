@@ -0,0 +1,1075 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The AtomBIOS interpreter below follows the command table semantics of the
+// Linux atom.c implementation. Instead of touching hardware every register
+// access is redirected to a RegisterFile, which records a trace of what the
+// table would have programmed.
+
+const (
+	AtomCTSizePtr = 0
+	AtomCTWSPtr   = 4
+	AtomCTPSPtr   = 5
+	AtomCTPSMask  = 0x7F
+	AtomCTCode    = 6
+
+	AtomOpCount = 127
+	AtomOpEOT   = 91
+
+	AtomCaseMagic = 0x63
+	AtomCaseEnd   = 0x5A5A
+
+	AtomArgReg = 0
+	AtomArgPS  = 1
+	AtomArgWS  = 2
+	AtomArgFB  = 3
+	AtomArgID  = 4
+	AtomArgImm = 5
+	AtomArgPLL = 6
+	AtomArgMC  = 7
+
+	AtomSrcDword  = 0
+	AtomSrcWord0  = 1
+	AtomSrcWord8  = 2
+	AtomSrcWord16 = 3
+	AtomSrcByte0  = 4
+	AtomSrcByte8  = 5
+	AtomSrcByte16 = 6
+	AtomSrcByte24 = 7
+
+	AtomWSQuotient   = 0x40
+	AtomWSRemainder  = 0x41
+	AtomWSDataPtr    = 0x42
+	AtomWSShift      = 0x43
+	AtomWSOrMask     = 0x44
+	AtomWSAndMask    = 0x45
+	AtomWSFBWindow   = 0x46
+	AtomWSAttributes = 0x47
+	AtomWSRegPtr     = 0x48
+
+	AtomPortATI   = 0
+	AtomPortPCI   = 1
+	AtomPortSysIO = 2
+
+	AtomIOMM    = 0
+	AtomIOPCI   = 1
+	AtomIOSysIO = 2
+	AtomIOIIO   = 0x80
+
+	AtomIIONop       = 0
+	AtomIIOStart     = 1
+	AtomIIORead      = 2
+	AtomIIOWrite     = 3
+	AtomIIOClear     = 4
+	AtomIIOSet       = 5
+	AtomIIOMoveIndex = 6
+	AtomIIOMoveAttr  = 7
+	AtomIIOMoveData  = 8
+	AtomIIOEnd       = 9
+
+	AtomCondAlways       = 0
+	AtomCondEqual        = 1
+	AtomCondBelow        = 2
+	AtomCondAbove        = 3
+	AtomCondBelowOrEqual = 4
+	AtomCondAboveOrEqual = 5
+	AtomCondNotEqual     = 6
+
+	AtomMaxParameters = 256
+	AtomMaxCallDepth  = 32
+	AtomMaxSteps      = 1000000
+)
+
+var atomArgMask = [8]uint32{0xFFFFFFFF, 0xFFFF, 0xFFFF00, 0xFFFF0000, 0xFF, 0xFF00, 0xFF0000, 0xFF000000}
+var atomArgShift = [8]uint32{0, 0, 8, 16, 0, 8, 16, 24}
+var atomDefDst = [8]byte{0, 0, 1, 2, 0, 1, 2, 3}
+var atomIIOLen = [10]int{1, 2, 3, 3, 3, 3, 4, 4, 4, 3}
+
+var atomDstToSrc = [8][4]byte{
+	{0, 0, 0, 0},
+	{1, 2, 3, 0},
+	{1, 2, 3, 0},
+	{1, 2, 3, 0},
+	{4, 5, 6, 7},
+	{4, 5, 6, 7},
+	{4, 5, 6, 7},
+	{4, 5, 6, 7},
+}
+
+// Order of the entries in ATOM_MASTER_LIST_OF_COMMAND_TABLES.
+var commandTableNames = []string{
+	"ASIC_Init",
+	"GetDisplaySurfaceSize",
+	"ASIC_RegistersInit",
+	"VRAM_BlockVenderDetection",
+	"DIGxEncoderControl",
+	"MemoryControllerInit",
+	"EnableCRTCMemReq",
+	"MemoryParamAdjust",
+	"DVOEncoderControl",
+	"GPIOPinControl",
+	"SetEngineClock",
+	"SetMemoryClock",
+	"SetPixelClock",
+	"EnableDispPowerGating",
+	"ResetMemoryDLL",
+	"ResetMemoryDevice",
+	"MemoryPLLInit",
+	"AdjustDisplayPll",
+	"AdjustMemoryController",
+	"EnableASIC_StaticPwrMgt",
+	"SetUniphyInstance",
+	"DAC_LoadDetection",
+	"LVTMAEncoderControl",
+	"HW_Misc_Operation",
+	"DAC1EncoderControl",
+	"DAC2EncoderControl",
+	"DVOOutputControl",
+	"CV1OutputControl",
+	"GetConditionalGoldenSetting",
+	"SMC_Init",
+	"PatchMCSetting",
+	"MC_SEQ_Control",
+	"Gfx_Harvesting",
+	"EnableScaler",
+	"BlankCRTC",
+	"EnableCRTC",
+	"GetPixelClock",
+	"EnableVGA_Render",
+	"GetSCLKOverMCLKRatio",
+	"SetCRTC_Timing",
+	"SetCRTC_OverScan",
+	"GetSMUClockInfo",
+	"SelectCRTC_Source",
+	"EnableGraphSurfaces",
+	"UpdateCRTC_DoubleBufferRegisters",
+	"LUT_AutoFill",
+	"EnableHW_IconCursor",
+	"GetMemoryClock",
+	"GetEngineClock",
+	"SetCRTC_UsingDTDTiming",
+	"ExternalEncoderControl",
+	"LVTMAOutputControl",
+	"VRAM_BlockDetectionByStrap",
+	"MemoryCleanUp",
+	"ProcessI2cChannelTransaction",
+	"WriteOneByteToHWAssistedI2C",
+	"ReadHWAssistedI2CStatus",
+	"SpeedFanControl",
+	"PowerConnectorDetection",
+	"MC_Synchronization",
+	"ComputeMemoryEnginePLL",
+	"Gfx_Init",
+	"VRAM_GetCurrentInfoBlock",
+	"DynamicMemorySettings",
+	"MemoryTraining",
+	"EnableSpreadSpectrumOnPPLL",
+	"TMDSAOutputControl",
+	"SetVoltage",
+	"DAC1OutputControl",
+	"ReadEfuseValue",
+	"DAC2OutputControl",
+	"ComputeMemoryClockParam",
+	"ClockSource",
+	"MemoryDeviceInit",
+	"GetDispObjectInfo",
+	"DIG1EncoderControl",
+	"DIG2EncoderControl",
+	"DIG1TransmitterControl",
+	"DIG2TransmitterControl",
+	"ProcessAuxChannelTransaction",
+	"DPEncoderService",
+	"GetVoltageInfo",
+}
+
+// RegisterAccess is a single traced access to the simulated register file.
+type RegisterAccess struct {
+	Table string
+	Space string
+	Index uint32
+	Value uint32
+	Write bool
+}
+
+// RegisterFile is an in-memory model of the MMIO, IO, PLL and MC register
+// spaces. Registers that were never written read back as zero unless they
+// were preloaded.
+type RegisterFile struct {
+	MMIO       map[uint32]uint32
+	IO         map[uint32]uint32
+	PLL        map[uint32]uint32
+	MC         map[uint32]uint32
+	FB         map[uint32]uint32
+	TraceReads bool
+	Trace      []RegisterAccess
+}
+
+func NewRegisterFile() *RegisterFile {
+	return &RegisterFile{
+		MMIO: map[uint32]uint32{},
+		IO:   map[uint32]uint32{},
+		PLL:  map[uint32]uint32{},
+		MC:   map[uint32]uint32{},
+		FB:   map[uint32]uint32{},
+	}
+}
+
+func (r *RegisterFile) space(name string) map[uint32]uint32 {
+	switch name {
+	case "MMIO":
+		return r.MMIO
+	case "IO":
+		return r.IO
+	case "PLL":
+		return r.PLL
+	case "MC":
+		return r.MC
+	}
+	return r.FB
+}
+
+func (r *RegisterFile) read(table string, name string, index uint32) uint32 {
+	value := r.space(name)[index]
+	if r.TraceReads {
+		r.Trace = append(r.Trace, RegisterAccess{table, name, index, value, false})
+	}
+	return value
+}
+
+func (r *RegisterFile) write(table string, name string, index uint32, value uint32) {
+	r.space(name)[index] = value
+	r.Trace = append(r.Trace, RegisterAccess{table, name, index, value, true})
+}
+
+// Preload parses "index=value" assignments into one of the register spaces.
+func (r *RegisterFile) Preload(name string, assignments []string) error {
+	for _, assignment := range assignments {
+		parts := strings.SplitN(assignment, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid %s register assignment %q, expected index=value", name, assignment)
+		}
+		index, err := strconv.ParseUint(parts[0], 0, 32)
+		if err != nil {
+			return fmt.Errorf("invalid %s register index %q", name, parts[0])
+		}
+		value, err := strconv.ParseUint(parts[1], 0, 32)
+		if err != nil {
+			return fmt.Errorf("invalid %s register value %q", name, parts[1])
+		}
+		r.space(name)[uint32(index)] = uint32(value)
+	}
+	return nil
+}
+
+// atomError aborts the execution of a command table. It is raised with panic
+// deep inside the operand decoders and recovered in executeCommandTable.
+type atomError struct {
+	message string
+}
+
+func (e atomError) Error() string {
+	return e.message
+}
+
+func atomAbort(format string, args ...interface{}) {
+	panic(atomError{fmt.Sprintf(format, args...)})
+}
+
+// atomContext holds the state shared by all tables of one execution.
+type atomContext struct {
+	rom       []byte
+	cmdTable  int
+	dataTable int
+	regs      *RegisterFile
+	iio       map[int]int
+	dataBlock uint32
+	regBlock  uint32
+	fbBase    uint32
+	ioMode    int
+	ioAttr    uint32
+	shift     uint32
+	divmul    [2]uint32
+	steps     int
+	depth     int
+}
+
+// atomExecContext holds the state of a single executing table.
+type atomExecContext struct {
+	ctx     *atomContext
+	table   string
+	start   int
+	ps      []uint32
+	psShift int
+	ws      []uint32
+	csEqual bool
+	csAbove bool
+}
+
+type atomOpcode struct {
+	op  func(*atomExecContext, *int, int)
+	arg int
+}
+
+var atomOpcodes [AtomOpCount]atomOpcode
+
+func init() {
+	// Opcodes come in groups of six, one per destination operand type.
+	group := func(first int, op func(*atomExecContext, *int, int)) {
+		args := []int{AtomArgReg, AtomArgPS, AtomArgWS, AtomArgFB, AtomArgPLL, AtomArgMC}
+		for i, arg := range args {
+			atomOpcodes[first+i] = atomOpcode{op, arg}
+		}
+	}
+	group(1, atomOpMove)
+	group(7, atomOpAnd)
+	group(13, atomOpOr)
+	group(19, atomOpShiftLeft)
+	group(25, atomOpShiftRight)
+	group(31, atomOpMul)
+	group(37, atomOpDiv)
+	group(43, atomOpAdd)
+	group(49, atomOpSub)
+	atomOpcodes[55] = atomOpcode{atomOpSetPort, AtomPortATI}
+	atomOpcodes[56] = atomOpcode{atomOpSetPort, AtomPortPCI}
+	atomOpcodes[57] = atomOpcode{atomOpSetPort, AtomPortSysIO}
+	atomOpcodes[58] = atomOpcode{atomOpSetRegBlock, 0}
+	atomOpcodes[59] = atomOpcode{atomOpSetFBBase, 0}
+	group(60, atomOpCompare)
+	atomOpcodes[66] = atomOpcode{atomOpSwitch, 0}
+	for cond := AtomCondAlways; cond <= AtomCondNotEqual; cond++ {
+		atomOpcodes[67+cond] = atomOpcode{atomOpJump, cond}
+	}
+	group(74, atomOpTest)
+	atomOpcodes[80] = atomOpcode{atomOpDelay, 1000}
+	atomOpcodes[81] = atomOpcode{atomOpDelay, 1}
+	atomOpcodes[82] = atomOpcode{atomOpCallTable, 0}
+	atomOpcodes[83] = atomOpcode{atomOpNop, 0}
+	group(84, atomOpClear)
+	atomOpcodes[90] = atomOpcode{atomOpNop, 0}
+	atomOpcodes[91] = atomOpcode{atomOpNop, 0}
+	group(92, atomOpMask)
+	atomOpcodes[98] = atomOpcode{atomOpSkipByte, 0}
+	atomOpcodes[99] = atomOpcode{atomOpNop, 0}
+	atomOpcodes[100] = atomOpcode{atomOpUnsupported, 0}
+	atomOpcodes[101] = atomOpcode{atomOpUnsupported, 0}
+	atomOpcodes[102] = atomOpcode{atomOpSetDataBlock, 0}
+	group(103, atomOpXor)
+	group(109, atomOpShl)
+	group(115, atomOpShr)
+	atomOpcodes[121] = atomOpcode{atomOpSkipByte, 0}
+	atomOpcodes[122] = atomOpcode{atomOpProcessDS, 0}
+	atomOpcodes[123] = atomOpcode{atomOpMul32, AtomArgPS}
+	atomOpcodes[124] = atomOpcode{atomOpMul32, AtomArgWS}
+	atomOpcodes[125] = atomOpcode{atomOpDiv32, AtomArgPS}
+	atomOpcodes[126] = atomOpcode{atomOpDiv32, AtomArgWS}
+}
+
+// commandTableIndex resolves a command table by name (case insensitive) or
+// by its numeric index in the master command table.
+func commandTableIndex(name string) (int, error) {
+	for i, tableName := range commandTableNames {
+		if strings.EqualFold(tableName, name) {
+			return i, nil
+		}
+	}
+	index, err := strconv.ParseUint(name, 0, 8)
+	if err != nil || int(index) >= len(commandTableNames) {
+		return 0, fmt.Errorf("unknown command table %q", name)
+	}
+	return int(index), nil
+}
+
+// executeCommandTable runs a command table of the ROM against the register
+// file. Parameters are passed in and returned through params, just like the
+// parameter space of the real interpreter.
+func executeCommandTable(rom []byte, index int, params []uint32, regs *RegisterFile) (err error) {
+	ctx := &atomContext{
+		rom:  rom,
+		regs: regs,
+		iio:  map[int]int{},
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			abort, ok := r.(atomError)
+			if !ok {
+				panic(r)
+			}
+			err = abort
+		}
+	}()
+
+	headerOffset := int(ctx.u16(int(ROM_HEADER_PTR)))
+	if headerOffset >= len(rom) {
+		return fmt.Errorf("ROM header at 0x%x is out of range", headerOffset)
+	}
+	header := AtomRomHeader{}
+	unpack(rom, uint16(headerOffset), &header)
+	ctx.cmdTable = int(header.MasterCommandTableOffset)
+	ctx.dataTable = int(header.MasterDataTableOffset)
+	if ctx.dataTable >= len(rom) {
+		return fmt.Errorf("master data table at 0x%x is out of range", ctx.dataTable)
+	}
+	dataTables := AtomDataTables{}
+	unpack(rom, uint16(ctx.dataTable), &dataTables)
+	ctx.indexIIO(int(dataTables.IndirectIOAccess))
+
+	if len(params) < AtomMaxParameters {
+		return fmt.Errorf("parameter space must hold %d entries", AtomMaxParameters)
+	}
+	ctx.executeTable(index, params)
+	return nil
+}
+
+func (c *atomContext) u8(offset int) uint32 {
+	if offset < 0 || offset >= len(c.rom) {
+		atomAbort("read outside of ROM at 0x%x", offset)
+	}
+	return uint32(c.rom[offset])
+}
+
+func (c *atomContext) u16(offset int) uint32 {
+	return c.u8(offset) | c.u8(offset+1)<<8
+}
+
+func (c *atomContext) u32(offset int) uint32 {
+	return c.u16(offset) | c.u16(offset+2)<<16
+}
+
+// indexIIO locates the indirect IO programs of the IndirectIOAccess table.
+func (c *atomContext) indexIIO(base int) {
+	if base == 0 {
+		return
+	}
+	base += 4
+	for c.u8(base) == AtomIIOStart {
+		c.iio[int(c.u8(base+1))] = base + 2
+		base += 2
+		for c.u8(base) != AtomIIOEnd {
+			op := int(c.u8(base))
+			if op >= len(atomIIOLen) {
+				atomAbort("unknown indirect IO opcode 0x%x at 0x%x", op, base)
+			}
+			base += atomIIOLen[op]
+		}
+		base += 3
+	}
+}
+
+func (c *atomContext) executeIIO(base int, index uint32, data uint32) uint32 {
+	mask := func(bits uint32) uint32 {
+		return 0xFFFFFFFF >> (32 - bits)
+	}
+	temp := uint32(0xCDCDCDCD)
+	for {
+		switch c.u8(base) {
+		case AtomIIONop:
+			base++
+		case AtomIIORead:
+			temp = c.regs.read("iio", "IO", c.u16(base+1))
+			base += 3
+		case AtomIIOWrite:
+			c.regs.write("iio", "IO", c.u16(base+1), temp)
+			base += 3
+		case AtomIIOClear:
+			temp &= ^(mask(c.u8(base+1)) << c.u8(base+2))
+			base += 3
+		case AtomIIOSet:
+			temp |= mask(c.u8(base+1)) << c.u8(base+2)
+			base += 3
+		case AtomIIOMoveIndex, AtomIIOMoveAttr, AtomIIOMoveData:
+			source := index
+			if c.u8(base) == AtomIIOMoveAttr {
+				source = c.ioAttr
+			} else if c.u8(base) == AtomIIOMoveData {
+				source = data
+			}
+			bits := mask(c.u8(base + 1))
+			temp &= ^(bits << c.u8(base+3))
+			temp |= ((source >> c.u8(base+2)) & bits) << c.u8(base+3)
+			base += 4
+		case AtomIIOEnd:
+			return temp
+		default:
+			atomAbort("unknown indirect IO opcode 0x%x at 0x%x", c.u8(base), base)
+		}
+	}
+}
+
+func (c *atomContext) executeTable(index int, params []uint32) {
+	if index < 0 || index >= len(commandTableNames) {
+		atomAbort("command table index %d out of range", index)
+	}
+	name := commandTableNames[index]
+	base := int(c.u16(c.cmdTable + 4 + 2*index))
+	if base == 0 {
+		atomAbort("command table %s is not present in this ROM", name)
+	}
+	c.depth++
+	if c.depth > AtomMaxCallDepth {
+		atomAbort("command table call depth exceeded in %s", name)
+	}
+	defer func() { c.depth-- }()
+
+	ws := int(c.u8(base + AtomCTWSPtr))
+	ps := int(c.u8(base+AtomCTPSPtr) & AtomCTPSMask)
+	e := &atomExecContext{
+		ctx:     c,
+		table:   name,
+		start:   base,
+		ps:      params,
+		psShift: ps / 4,
+		ws:      make([]uint32, ws),
+	}
+
+	ptr := base + AtomCTCode
+	for {
+		op := int(c.u8(ptr))
+		ptr++
+		if op <= 0 || op >= AtomOpCount {
+			break
+		}
+		c.steps++
+		if c.steps > AtomMaxSteps {
+			atomAbort("step limit exceeded in %s at 0x%x, the table is probably looping", name, ptr-1)
+		}
+		atomOpcodes[op].op(e, &ptr, atomOpcodes[op].arg)
+		if op == AtomOpEOT {
+			break
+		}
+	}
+}
+
+func (e *atomExecContext) param(index int) *uint32 {
+	if index >= len(e.ps) {
+		atomAbort("parameter %d out of range in %s", index, e.table)
+	}
+	return &e.ps[index]
+}
+
+func (e *atomExecContext) workspace(index int) *uint32 {
+	if index >= len(e.ws) {
+		atomAbort("workspace entry %d out of range in %s", index, e.table)
+	}
+	return &e.ws[index]
+}
+
+func (e *atomExecContext) getSrcInt(attr byte, ptr *int, saved *uint32) uint32 {
+	c := e.ctx
+	align := (attr >> 3) & 7
+	arg := attr & 7
+	var val uint32
+	switch arg {
+	case AtomArgReg:
+		index := c.u16(*ptr) + c.regBlock
+		*ptr += 2
+		switch {
+		case c.ioMode == AtomIOMM:
+			val = c.regs.read(e.table, "MMIO", index)
+		case c.ioMode&AtomIOIIO != 0:
+			base, found := c.iio[c.ioMode&0x7F]
+			if !found {
+				atomAbort("undefined indirect IO read method %d in %s", c.ioMode&0x7F, e.table)
+			}
+			val = c.executeIIO(base, index, 0)
+		default:
+			atomAbort("PCI and SYSIO registers are not supported (%s)", e.table)
+		}
+	case AtomArgPS:
+		val = *e.param(int(c.u8(*ptr)))
+		*ptr++
+	case AtomArgWS:
+		index := int(c.u8(*ptr))
+		*ptr++
+		switch index {
+		case AtomWSQuotient:
+			val = c.divmul[0]
+		case AtomWSRemainder:
+			val = c.divmul[1]
+		case AtomWSDataPtr:
+			val = c.dataBlock
+		case AtomWSShift:
+			val = c.shift
+		case AtomWSOrMask:
+			val = 1 << c.shift
+		case AtomWSAndMask:
+			val = ^(1 << c.shift)
+		case AtomWSFBWindow:
+			val = c.fbBase
+		case AtomWSAttributes:
+			val = c.ioAttr
+		case AtomWSRegPtr:
+			val = c.regBlock
+		default:
+			val = *e.workspace(index)
+		}
+	case AtomArgID:
+		index := c.u16(*ptr)
+		*ptr += 2
+		val = c.u32(int(index + c.dataBlock))
+	case AtomArgFB:
+		index := c.u8(*ptr)
+		*ptr++
+		val = c.regs.read(e.table, "FB", c.fbBase/4+index)
+	case AtomArgImm:
+		switch align {
+		case AtomSrcDword:
+			val = c.u32(*ptr)
+			*ptr += 4
+		case AtomSrcWord0, AtomSrcWord8, AtomSrcWord16:
+			val = c.u16(*ptr)
+			*ptr += 2
+		default:
+			val = c.u8(*ptr)
+			*ptr++
+		}
+		return val
+	case AtomArgPLL:
+		val = c.regs.read(e.table, "PLL", c.u8(*ptr))
+		*ptr++
+	case AtomArgMC:
+		val = c.regs.read(e.table, "MC", c.u8(*ptr))
+		*ptr++
+	}
+	if saved != nil {
+		*saved = val
+	}
+	return (val & atomArgMask[align]) >> atomArgShift[align]
+}
+
+func (e *atomExecContext) getSrc(attr byte, ptr *int) uint32 {
+	return e.getSrcInt(attr, ptr, nil)
+}
+
+func (e *atomExecContext) getSrcDirect(align byte, ptr *int) uint32 {
+	c := e.ctx
+	var val uint32
+	switch align {
+	case AtomSrcDword:
+		val = c.u32(*ptr)
+		*ptr += 4
+	case AtomSrcWord0, AtomSrcWord8, AtomSrcWord16:
+		val = c.u16(*ptr)
+		*ptr += 2
+	default:
+		val = c.u8(*ptr)
+		*ptr++
+	}
+	return val
+}
+
+func dstAttr(arg int, attr byte) byte {
+	return byte(arg) | atomDstToSrc[(attr>>3)&7][(attr>>6)&3]<<3
+}
+
+func (e *atomExecContext) getDst(arg int, attr byte, ptr *int, saved *uint32) uint32 {
+	return e.getSrcInt(dstAttr(arg, attr), ptr, saved)
+}
+
+func (e *atomExecContext) skipSrcInt(attr byte, ptr *int) {
+	align := (attr >> 3) & 7
+	switch attr & 7 {
+	case AtomArgReg, AtomArgID:
+		*ptr += 2
+	case AtomArgImm:
+		switch align {
+		case AtomSrcDword:
+			*ptr += 4
+		case AtomSrcWord0, AtomSrcWord8, AtomSrcWord16:
+			*ptr += 2
+		default:
+			*ptr++
+		}
+	default:
+		*ptr++
+	}
+}
+
+func (e *atomExecContext) skipDst(arg int, attr byte, ptr *int) {
+	e.skipSrcInt(dstAttr(arg, attr), ptr)
+}
+
+func (e *atomExecContext) putDst(arg int, attr byte, ptr *int, val uint32, saved uint32) {
+	c := e.ctx
+	align := atomDstToSrc[(attr>>3)&7][(attr>>6)&3]
+	val <<= atomArgShift[align]
+	val &= atomArgMask[align]
+	saved &= ^atomArgMask[align]
+	val |= saved
+	switch arg {
+	case AtomArgReg:
+		index := c.u16(*ptr) + c.regBlock
+		*ptr += 2
+		switch {
+		case c.ioMode == AtomIOMM:
+			// MM_INDEX takes a byte address.
+			if index == 0 {
+				val <<= 2
+			}
+			c.regs.write(e.table, "MMIO", index, val)
+		case c.ioMode&AtomIOIIO != 0:
+			base, found := c.iio[c.ioMode&0x7F]
+			if !found {
+				atomAbort("undefined indirect IO write method %d in %s", c.ioMode&0x7F, e.table)
+			}
+			c.executeIIO(base, index, val)
+		default:
+			atomAbort("PCI and SYSIO registers are not supported (%s)", e.table)
+		}
+	case AtomArgPS:
+		*e.param(int(c.u8(*ptr))) = val
+		*ptr++
+	case AtomArgWS:
+		index := int(c.u8(*ptr))
+		*ptr++
+		switch index {
+		case AtomWSQuotient:
+			c.divmul[0] = val
+		case AtomWSRemainder:
+			c.divmul[1] = val
+		case AtomWSDataPtr:
+			c.dataBlock = val
+		case AtomWSShift:
+			c.shift = val
+		case AtomWSOrMask, AtomWSAndMask:
+		case AtomWSFBWindow:
+			c.fbBase = val
+		case AtomWSAttributes:
+			c.ioAttr = val
+		case AtomWSRegPtr:
+			c.regBlock = val
+		default:
+			*e.workspace(index) = val
+		}
+	case AtomArgFB:
+		c.regs.write(e.table, "FB", c.fbBase/4+c.u8(*ptr), val)
+		*ptr++
+	case AtomArgPLL:
+		c.regs.write(e.table, "PLL", c.u8(*ptr), val)
+		*ptr++
+	case AtomArgMC:
+		c.regs.write(e.table, "MC", c.u8(*ptr), val)
+		*ptr++
+	}
+}
+
+func atomOpMove(e *atomExecContext, ptr *int, arg int) {
+	attr := byte(e.ctx.u8(*ptr))
+	*ptr++
+	dptr := *ptr
+	saved := uint32(0xCDCDCDCD)
+	if (attr>>3)&7 != AtomSrcDword {
+		e.getDst(arg, attr, ptr, &saved)
+	} else {
+		e.skipDst(arg, attr, ptr)
+	}
+	src := e.getSrc(attr, ptr)
+	e.putDst(arg, attr, &dptr, src, saved)
+}
+
+// atomBinaryOp implements the read-modify-write operations that combine the
+// destination with a source operand.
+func atomBinaryOp(e *atomExecContext, ptr *int, arg int, op func(dst, src uint32) uint32) {
+	attr := byte(e.ctx.u8(*ptr))
+	*ptr++
+	dptr := *ptr
+	var saved uint32
+	dst := e.getDst(arg, attr, ptr, &saved)
+	src := e.getSrc(attr, ptr)
+	e.putDst(arg, attr, &dptr, op(dst, src), saved)
+}
+
+func atomOpAnd(e *atomExecContext, ptr *int, arg int) {
+	atomBinaryOp(e, ptr, arg, func(dst, src uint32) uint32 { return dst & src })
+}
+
+func atomOpOr(e *atomExecContext, ptr *int, arg int) {
+	atomBinaryOp(e, ptr, arg, func(dst, src uint32) uint32 { return dst | src })
+}
+
+func atomOpXor(e *atomExecContext, ptr *int, arg int) {
+	atomBinaryOp(e, ptr, arg, func(dst, src uint32) uint32 { return dst ^ src })
+}
+
+func atomOpAdd(e *atomExecContext, ptr *int, arg int) {
+	atomBinaryOp(e, ptr, arg, func(dst, src uint32) uint32 { return dst + src })
+}
+
+func atomOpSub(e *atomExecContext, ptr *int, arg int) {
+	atomBinaryOp(e, ptr, arg, func(dst, src uint32) uint32 { return dst - src })
+}
+
+func atomOpMul(e *atomExecContext, ptr *int, arg int) {
+	attr := byte(e.ctx.u8(*ptr))
+	*ptr++
+	dst := e.getDst(arg, attr, ptr, nil)
+	src := e.getSrc(attr, ptr)
+	e.ctx.divmul[0] = dst * src
+}
+
+func atomOpMul32(e *atomExecContext, ptr *int, arg int) {
+	attr := byte(e.ctx.u8(*ptr))
+	*ptr++
+	dst := e.getDst(arg, attr, ptr, nil)
+	src := e.getSrc(attr, ptr)
+	result := uint64(dst) * uint64(src)
+	e.ctx.divmul[0] = uint32(result)
+	e.ctx.divmul[1] = uint32(result >> 32)
+}
+
+func atomOpDiv(e *atomExecContext, ptr *int, arg int) {
+	attr := byte(e.ctx.u8(*ptr))
+	*ptr++
+	dst := e.getDst(arg, attr, ptr, nil)
+	src := e.getSrc(attr, ptr)
+	if src != 0 {
+		e.ctx.divmul[0] = dst / src
+		e.ctx.divmul[1] = dst % src
+	} else {
+		e.ctx.divmul[0] = 0
+		e.ctx.divmul[1] = 0
+	}
+}
+
+func atomOpDiv32(e *atomExecContext, ptr *int, arg int) {
+	attr := byte(e.ctx.u8(*ptr))
+	*ptr++
+	dst := e.getDst(arg, attr, ptr, nil)
+	src := e.getSrc(attr, ptr)
+	if src != 0 {
+		value := uint64(dst) | uint64(e.ctx.divmul[1])<<32
+		value /= uint64(src)
+		e.ctx.divmul[0] = uint32(value)
+		e.ctx.divmul[1] = uint32(value >> 32)
+	} else {
+		e.ctx.divmul[0] = 0
+		e.ctx.divmul[1] = 0
+	}
+}
+
+// SHIFT_LEFT and SHIFT_RIGHT operate on the default alignment of the
+// destination and take the shift amount as an immediate byte.
+func atomShift(e *atomExecContext, ptr *int, arg int, left bool) {
+	attr := byte(e.ctx.u8(*ptr))
+	*ptr++
+	dptr := *ptr
+	attr &= 0x38
+	attr |= atomDefDst[attr>>3] << 6
+	var saved uint32
+	dst := e.getDst(arg, attr, ptr, &saved)
+	shift := byte(e.getSrcDirect(AtomSrcByte0, ptr))
+	if left {
+		dst <<= shift
+	} else {
+		dst >>= shift
+	}
+	e.putDst(arg, attr, &dptr, dst, saved)
+}
+
+// SHL and SHR keep the attribute, shift the full destination value by a
+// source operand and mask the result back into the destination alignment.
+func atomShl(e *atomExecContext, ptr *int, arg int, left bool) {
+	attr := byte(e.ctx.u8(*ptr))
+	*ptr++
+	dptr := *ptr
+	dstAlign := atomDstToSrc[(attr>>3)&7][(attr>>6)&3]
+	var saved uint32
+	e.getDst(arg, attr, ptr, &saved)
+	dst := saved
+	shift := byte(e.getSrc(attr, ptr))
+	if left {
+		dst <<= shift
+	} else {
+		dst >>= shift
+	}
+	dst &= atomArgMask[dstAlign]
+	dst >>= atomArgShift[dstAlign]
+	e.putDst(arg, attr, &dptr, dst, saved)
+}
+
+func atomOpShiftLeft(e *atomExecContext, ptr *int, arg int) {
+	atomShift(e, ptr, arg, true)
+}
+
+func atomOpShiftRight(e *atomExecContext, ptr *int, arg int) {
+	atomShift(e, ptr, arg, false)
+}
+
+func atomOpShl(e *atomExecContext, ptr *int, arg int) {
+	atomShl(e, ptr, arg, true)
+}
+
+func atomOpShr(e *atomExecContext, ptr *int, arg int) {
+	atomShl(e, ptr, arg, false)
+}
+
+func atomOpCompare(e *atomExecContext, ptr *int, arg int) {
+	attr := byte(e.ctx.u8(*ptr))
+	*ptr++
+	dst := e.getDst(arg, attr, ptr, nil)
+	src := e.getSrc(attr, ptr)
+	e.csEqual = dst == src
+	e.csAbove = dst > src
+}
+
+func atomOpTest(e *atomExecContext, ptr *int, arg int) {
+	attr := byte(e.ctx.u8(*ptr))
+	*ptr++
+	dst := e.getDst(arg, attr, ptr, nil)
+	src := e.getSrc(attr, ptr)
+	e.csEqual = dst&src == 0
+}
+
+func atomOpClear(e *atomExecContext, ptr *int, arg int) {
+	attr := byte(e.ctx.u8(*ptr))
+	*ptr++
+	dptr := *ptr
+	attr &= 0x38
+	attr |= atomDefDst[attr>>3] << 6
+	var saved uint32
+	e.getDst(arg, attr, ptr, &saved)
+	e.putDst(arg, attr, &dptr, 0, saved)
+}
+
+func atomOpMask(e *atomExecContext, ptr *int, arg int) {
+	attr := byte(e.ctx.u8(*ptr))
+	*ptr++
+	dptr := *ptr
+	var saved uint32
+	dst := e.getDst(arg, attr, ptr, &saved)
+	mask := e.getSrcDirect((attr>>3)&7, ptr)
+	src := e.getSrc(attr, ptr)
+	dst &= mask
+	dst |= src
+	e.putDst(arg, attr, &dptr, dst, saved)
+}
+
+func atomOpJump(e *atomExecContext, ptr *int, arg int) {
+	target := int(e.ctx.u16(*ptr))
+	*ptr += 2
+	execute := false
+	switch arg {
+	case AtomCondAlways:
+		execute = true
+	case AtomCondEqual:
+		execute = e.csEqual
+	case AtomCondBelow:
+		execute = !(e.csAbove || e.csEqual)
+	case AtomCondAbove:
+		execute = e.csAbove
+	case AtomCondBelowOrEqual:
+		execute = !e.csAbove
+	case AtomCondAboveOrEqual:
+		execute = e.csAbove || e.csEqual
+	case AtomCondNotEqual:
+		execute = !e.csEqual
+	}
+	if execute {
+		*ptr = e.start + target
+	}
+}
+
+func atomOpSwitch(e *atomExecContext, ptr *int, arg int) {
+	c := e.ctx
+	attr := byte(c.u8(*ptr))
+	*ptr++
+	src := e.getSrc(attr, ptr)
+	for c.u16(*ptr) != AtomCaseEnd {
+		if c.u8(*ptr) != AtomCaseMagic {
+			atomAbort("bad case in switch of %s at 0x%x", e.table, *ptr)
+		}
+		*ptr++
+		val := e.getSrc((attr&0x38)|AtomArgImm, ptr)
+		target := int(c.u16(*ptr))
+		if val == src {
+			*ptr = e.start + target
+			return
+		}
+		*ptr += 2
+	}
+	*ptr += 2
+}
+
+func atomOpSetPort(e *atomExecContext, ptr *int, arg int) {
+	c := e.ctx
+	switch arg {
+	case AtomPortATI:
+		port := int(c.u16(*ptr))
+		if port == 0 {
+			c.ioMode = AtomIOMM
+		} else {
+			c.ioMode = AtomIOIIO | port
+		}
+		*ptr += 2
+	case AtomPortPCI:
+		c.ioMode = AtomIOPCI
+		*ptr++
+	case AtomPortSysIO:
+		c.ioMode = AtomIOSysIO
+		*ptr++
+	}
+}
+
+func atomOpSetRegBlock(e *atomExecContext, ptr *int, arg int) {
+	e.ctx.regBlock = e.ctx.u16(*ptr)
+	*ptr += 2
+}
+
+func atomOpSetFBBase(e *atomExecContext, ptr *int, arg int) {
+	attr := byte(e.ctx.u8(*ptr))
+	*ptr++
+	e.ctx.fbBase = e.getSrc(attr, ptr)
+}
+
+func atomOpSetDataBlock(e *atomExecContext, ptr *int, arg int) {
+	c := e.ctx
+	index := int(c.u8(*ptr))
+	*ptr++
+	switch index {
+	case 0:
+		c.dataBlock = 0
+	case 255:
+		c.dataBlock = uint32(e.start)
+	default:
+		c.dataBlock = c.u16(c.dataTable + 4 + 2*index)
+	}
+}
+
+func atomOpCallTable(e *atomExecContext, ptr *int, arg int) {
+	c := e.ctx
+	index := int(c.u8(*ptr))
+	*ptr++
+	if index >= len(commandTableNames) || c.u16(c.cmdTable+4+2*index) == 0 {
+		return
+	}
+	if e.psShift > len(e.ps) {
+		atomAbort("parameter space exhausted calling table %d from %s", index, e.table)
+	}
+	c.executeTable(index, e.ps[e.psShift:])
+}
+
+func atomOpDelay(e *atomExecContext, ptr *int, arg int) {
+	// Delays are meaningless without hardware, just skip the count.
+	*ptr++
+}
+
+func atomOpProcessDS(e *atomExecContext, ptr *int, arg int) {
+	size := int(e.ctx.u16(*ptr))
+	*ptr += size + 2
+}
+
+func atomOpSkipByte(e *atomExecContext, ptr *int, arg int) {
+	*ptr++
+}
+
+func atomOpNop(e *atomExecContext, ptr *int, arg int) {
+}
+
+func atomOpUnsupported(e *atomExecContext, ptr *int, arg int) {
+	atomAbort("unsupported opcode 0x%x in %s at 0x%x", e.ctx.u8(*ptr-1), e.table, *ptr-1)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// The synthetic ROM holds a single ASIC_Init command table. Jump and case
+// targets are relative to the table start, the code starts 6 bytes in.
+const (
+	testRomHeader    = 0x100
+	testCommandTable = 0x180
+	testDataTable    = 0x200
+	testTable        = 0x300
+	testIIO          = 0x400
+	testDataBlock    = 0x480
+	testIIOPort      = 0x05
+)
+
+func le16(value uint16) []byte {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, value)
+	return b
+}
+
+func le32(value uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, value)
+	return b
+}
+
+func code(parts ...interface{}) []byte {
+	var b []byte
+	for _, part := range parts {
+		switch part := part.(type) {
+		case int:
+			b = append(b, byte(part))
+		case []byte:
+			b = append(b, part...)
+		}
+	}
+	return b
+}
+
+func testRom(table []byte) []byte {
+	rom := make([]byte, 0x500)
+	binary.LittleEndian.PutUint16(rom[ROM_HEADER_PTR:], testRomHeader)
+	binary.LittleEndian.PutUint16(rom[testRomHeader+30:], testCommandTable)
+	binary.LittleEndian.PutUint16(rom[testRomHeader+32:], testDataTable)
+	binary.LittleEndian.PutUint16(rom[testCommandTable+4:], testTable)
+
+	binary.LittleEndian.PutUint16(rom[testTable:], uint16(AtomCTCode+len(table)))
+	rom[testTable+AtomCTWSPtr] = 4
+	copy(rom[testTable+AtomCTCode:], table)
+
+	// FirmwareInfo points at a data block, IndirectIOAccess at a program
+	// that writes the index to port 0 and the data to port 4.
+	binary.LittleEndian.PutUint16(rom[testDataTable+4+2*4:], testDataBlock)
+	binary.LittleEndian.PutUint32(rom[testDataBlock+8:], 0x11223344)
+	binary.LittleEndian.PutUint16(rom[testDataTable+4+2*23:], testIIO)
+	copy(rom[testIIO+4:], code(
+		AtomIIOStart, testIIOPort,
+		AtomIIOMoveIndex, 16, 0, 0,
+		AtomIIOWrite, le16(0),
+		AtomIIOMoveData, 32, 0, 0,
+		AtomIIOWrite, le16(4),
+		AtomIIOEnd, 0, 0,
+	))
+	return rom
+}
+
+func mmioWrite(index uint32, value uint32) RegisterAccess {
+	return RegisterAccess{"ASIC_Init", "MMIO", index, value, true}
+}
+
+func TestExecuteCommandTable(t *testing.T) {
+	// jumpOver compares register 0x20 with value and jumps over a write of
+	// register 0x30 when the condition holds.
+	jumpOver := func(cond int, value uint32) []byte {
+		return code(
+			60, 0x05, le16(0x20), le32(value),
+			67+cond, le16(AtomCTCode+19),
+			1, 0x05, le16(0x30), le32(1),
+			AtomOpEOT,
+		)
+	}
+	written := []RegisterAccess{mmioWrite(0x30, 1)}
+
+	// switchOn selects on register 0x20 and writes the matching case number,
+	// or 0xff when no case matches, to register 0x30.
+	switchOn := code(
+		66, 0x00, le16(0x20),
+		AtomCaseMagic, le32(1), le16(AtomCTCode+31),
+		AtomCaseMagic, le32(2), le16(AtomCTCode+42),
+		le16(AtomCaseEnd),
+		1, 0x05, le16(0x30), le32(0xff),
+		67, le16(AtomCTCode+50),
+		1, 0x05, le16(0x30), le32(1),
+		67, le16(AtomCTCode+50),
+		1, 0x05, le16(0x30), le32(2),
+		AtomOpEOT,
+	)
+
+	tests := []struct {
+		name     string
+		table    []byte
+		preload  map[uint32]uint32
+		expected []RegisterAccess
+	}{
+		{"move dword", code(1, 0x05, le16(0x10), le32(0xdeadbeef), AtomOpEOT),
+			nil, []RegisterAccess{mmioWrite(0x10, 0xdeadbeef)}},
+		{"move byte into byte8", code(1, 0x65, le16(0x10), 0xab, AtomOpEOT),
+			map[uint32]uint32{0x10: 0x11223344}, []RegisterAccess{mmioWrite(0x10, 0x1122ab44)}},
+		{"move word16 into word0", code(1, 0x18, le16(0x31), le16(0x10), AtomOpEOT),
+			map[uint32]uint32{0x10: 0xaabbccdd, 0x31: 0x11112222}, []RegisterAccess{mmioWrite(0x31, 0x1111aabb)}},
+		{"move through parameter and workspace", code(3, 0x01, 0, 0, 1, 0x02, le16(0x30), 0, AtomOpEOT),
+			nil, []RegisterAccess{mmioWrite(0x30, 0x55)}},
+		{"mask", code(92, 0x05, le16(0x10), le32(0x00ffffff), le32(0x55), AtomOpEOT),
+			map[uint32]uint32{0x10: 0xffff0000}, []RegisterAccess{mmioWrite(0x10, 0x00ff0055)}},
+		{"shift left dword", code(19, 0x00, le16(0x10), 4, AtomOpEOT),
+			map[uint32]uint32{0x10: 0x1}, []RegisterAccess{mmioWrite(0x10, 0x10)}},
+		{"shift left byte8", code(19, 0x28, le16(0x10), 1, AtomOpEOT),
+			map[uint32]uint32{0x10: 0x300}, []RegisterAccess{mmioWrite(0x10, 0x600)}},
+		{"shift right dword", code(25, 0x00, le16(0x10), 4, AtomOpEOT),
+			map[uint32]uint32{0x10: 0x80}, []RegisterAccess{mmioWrite(0x10, 0x8)}},
+		{"shl byte8", code(109, 0x65, le16(0x10), 4, AtomOpEOT),
+			map[uint32]uint32{0x10: 0x12345678}, []RegisterAccess{mmioWrite(0x10, 0x12346778)}},
+		{"shr byte8", code(115, 0x65, le16(0x10), 4, AtomOpEOT),
+			map[uint32]uint32{0x10: 0x01234567}, []RegisterAccess{mmioWrite(0x10, 0x01233467)}},
+		{"jump always", jumpOver(AtomCondAlways, 5), map[uint32]uint32{0x20: 5}, nil},
+		{"jump equal taken", jumpOver(AtomCondEqual, 5), map[uint32]uint32{0x20: 5}, nil},
+		{"jump equal not taken", jumpOver(AtomCondEqual, 3), map[uint32]uint32{0x20: 5}, written},
+		{"jump below taken", jumpOver(AtomCondBelow, 7), map[uint32]uint32{0x20: 5}, nil},
+		{"jump below not taken", jumpOver(AtomCondBelow, 5), map[uint32]uint32{0x20: 5}, written},
+		{"jump above taken", jumpOver(AtomCondAbove, 3), map[uint32]uint32{0x20: 5}, nil},
+		{"jump above not taken", jumpOver(AtomCondAbove, 5), map[uint32]uint32{0x20: 5}, written},
+		{"jump below or equal taken", jumpOver(AtomCondBelowOrEqual, 5), map[uint32]uint32{0x20: 5}, nil},
+		{"jump below or equal not taken", jumpOver(AtomCondBelowOrEqual, 3), map[uint32]uint32{0x20: 5}, written},
+		{"jump above or equal taken", jumpOver(AtomCondAboveOrEqual, 5), map[uint32]uint32{0x20: 5}, nil},
+		{"jump above or equal not taken", jumpOver(AtomCondAboveOrEqual, 7), map[uint32]uint32{0x20: 5}, written},
+		{"jump not equal taken", jumpOver(AtomCondNotEqual, 3), map[uint32]uint32{0x20: 5}, nil},
+		{"jump not equal not taken", jumpOver(AtomCondNotEqual, 5), map[uint32]uint32{0x20: 5}, written},
+		{"switch first case", switchOn, map[uint32]uint32{0x20: 1}, []RegisterAccess{mmioWrite(0x30, 1)}},
+		{"switch second case", switchOn, map[uint32]uint32{0x20: 2}, []RegisterAccess{mmioWrite(0x30, 2)}},
+		{"switch no case", switchOn, map[uint32]uint32{0x20: 3}, []RegisterAccess{mmioWrite(0x30, 0xff)}},
+		{"indirect IO", code(55, le16(testIIOPort), 1, 0x05, le16(0x1234), le32(0xcafef00d), AtomOpEOT),
+			nil, []RegisterAccess{
+				{"iio", "IO", 0, 0xcdcd1234, true},
+				{"iio", "IO", 4, 0xcafef00d, true},
+			}},
+		{"data block", code(102, 4, 1, 0x04, le16(0x30), le16(8), AtomOpEOT),
+			nil, []RegisterAccess{mmioWrite(0x30, 0x11223344)}},
+		{"data block of the table", code(102, 255, 1, 0x04, le16(0x30), le16(AtomCTCode), AtomOpEOT),
+			nil, []RegisterAccess{mmioWrite(0x30, 0x0401ff66)}},
+	}
+
+	for _, test := range tests {
+		regs := NewRegisterFile()
+		for index, value := range test.preload {
+			regs.MMIO[index] = value
+		}
+		params := make([]uint32, AtomMaxParameters)
+		params[0] = 0x55
+		if err := executeCommandTable(testRom(test.table), 0, params, regs); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(regs.Trace, test.expected) {
+			t.Errorf("%s: trace is %v, expected %v", test.name, regs.Trace, test.expected)
+		}
+	}
+}
+
+func TestExecuteCommandTableErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		table []byte
+	}{
+		{"endless loop", code(67, le16(AtomCTCode))},
+		{"unsupported opcode", code(100)},
+		{"undefined indirect IO", code(55, le16(testIIOPort+1), 1, 0x05, le16(0x10), le32(1), AtomOpEOT)},
+		{"bad case", code(66, 0x05, le32(1), 0x00)},
+	}
+	for _, test := range tests {
+		params := make([]uint32, AtomMaxParameters)
+		if err := executeCommandTable(testRom(test.table), 0, params, NewRegisterFile()); err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ttacon/chalk"
+)
+
+// The amdgpu driver exposes its DPM state in /sys/class/drm/<card>/device:
+// pp_dpm_sclk and pp_dpm_mclk list the active clock levels, pp_od_clk_voltage
+// the overdrive levels with their voltages and limits, and the hwmon
+// directory the power cap and fan settings. The driver starts from the ROM
+// tables, so differences come from a soft pp_table, an overdrive script or
+// manual fan control.
+
+const sysfsDRMPath = "class/drm"
+
+// LiveState is the DPM state of a running card. Files the driver does not
+// provide are listed in Missing.
+type LiveState struct {
+	Sclk        []uint32 // MHz
+	Mclk        []uint32 // MHz
+	ODSclk      []DPMLevel
+	ODMclk      []DPMLevel
+	ODSclkMax   uint32 // MHz
+	ODMclkMax   uint32 // MHz
+	PowerCap    uint32 // W
+	PowerCapMax uint32 // W
+	FanMode     uint64 // pwm1_enable, 0 full speed, 1 manual, 2 automatic
+	FanMaxRPM   uint64
+	Missing     []string
+}
+
+var (
+	dpmLevelPattern     = regexp.MustCompile(`^(\d+):\s*(\d+)\s*[Mm][Hh]z`)
+	odLevelPattern      = regexp.MustCompile(`^(\d+):\s*(\d+)\s*[Mm][Hh]z\s+(\d+)\s*mV`)
+	odRangeClockPattern = regexp.MustCompile(`^(SCLK|MCLK):\s*(\d+)\s*[Mm][Hh]z\s+(\d+)\s*[Mm][Hh]z`)
+)
+
+func drmDevicePath(root string, card string) string {
+	return filepath.Join(root, sysfsDRMPath, filepath.Base(card), "device")
+}
+
+// parseDPMClocks parses pp_dpm_sclk or pp_dpm_mclk, "0: 300Mhz *" per level.
+func parseDPMClocks(text string) []uint32 {
+	clocks := []uint32{}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		match := dpmLevelPattern.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if match == nil {
+			continue
+		}
+		clock, _ := strconv.ParseUint(match[2], 10, 32)
+		clocks = append(clocks, uint32(clock))
+	}
+	return clocks
+}
+
+// parseODClockVoltage parses the OD_SCLK, OD_MCLK and OD_RANGE sections of
+// pp_od_clk_voltage into the state.
+func parseODClockVoltage(text string, state *LiveState) {
+	section := ""
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "OD_") {
+			section = strings.TrimSuffix(line, ":")
+			continue
+		}
+		if match := odLevelPattern.FindStringSubmatch(line); match != nil {
+			clock, _ := strconv.ParseUint(match[2], 10, 32)
+			voltage, _ := strconv.ParseUint(match[3], 10, 16)
+			level := DPMLevel{Clock: uint32(clock), Voltage: uint16(voltage)}
+			switch section {
+			case "OD_SCLK":
+				state.ODSclk = append(state.ODSclk, level)
+			case "OD_MCLK":
+				state.ODMclk = append(state.ODMclk, level)
+			}
+			continue
+		}
+		if match := odRangeClockPattern.FindStringSubmatch(line); match != nil && section == "OD_RANGE" {
+			max, _ := strconv.ParseUint(match[3], 10, 32)
+			if match[1] == "SCLK" {
+				state.ODSclkMax = uint32(max)
+			} else {
+				state.ODMclkMax = uint32(max)
+			}
+		}
+	}
+}
+
+// readLiveState reads the DPM state of a card from sysfs.
+func readLiveState(root string, card string) (LiveState, error) {
+	state := LiveState{}
+	device := drmDevicePath(root, card)
+	if _, err := os.Stat(device); err != nil {
+		return state, fmt.Errorf("card %s not found: %s", card, err)
+	}
+
+	readText := func(path string) (string, bool) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			state.Missing = append(state.Missing, filepath.Base(path))
+			return "", false
+		}
+		return string(data), true
+	}
+	readNumber := func(path string) (uint64, bool) {
+		text, found := readText(path)
+		if !found {
+			return 0, false
+		}
+		value, err := strconv.ParseUint(strings.TrimSpace(text), 10, 64)
+		return value, err == nil
+	}
+
+	if text, found := readText(filepath.Join(device, "pp_dpm_sclk")); found {
+		state.Sclk = parseDPMClocks(text)
+	}
+	if text, found := readText(filepath.Join(device, "pp_dpm_mclk")); found {
+		state.Mclk = parseDPMClocks(text)
+	}
+	if text, found := readText(filepath.Join(device, "pp_od_clk_voltage")); found {
+		parseODClockVoltage(text, &state)
+	}
+
+	hwmons, _ := filepath.Glob(filepath.Join(device, "hwmon", "hwmon*"))
+	if len(hwmons) == 0 {
+		state.Missing = append(state.Missing, "hwmon")
+		return state, nil
+	}
+	hwmon := hwmons[0]
+	// The power cap is in microwatts.
+	if value, found := readNumber(filepath.Join(hwmon, "power1_cap")); found {
+		state.PowerCap = uint32(value / 1000000)
+	}
+	if value, found := readNumber(filepath.Join(hwmon, "power1_cap_max")); found {
+		state.PowerCapMax = uint32(value / 1000000)
+	}
+	if value, found := readNumber(filepath.Join(hwmon, "pwm1_enable")); found {
+		state.FanMode = value
+	}
+	if value, found := readNumber(filepath.Join(hwmon, "fan1_max")); found {
+		state.FanMaxRPM = value
+	}
+	return state, nil
+}
+
+func liveCompare(root string, card string, filename string) {
+	buffer := readFile(filename)
+	bios := unpackData(buffer)
+	state, err := readLiveState(root, card)
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+
+	differences := 0
+	differences += compareLevels("GPU clock", sclkLevels(bios), state.Sclk)
+	differences += compareLevels("Memory clock", mclkLevels(bios), state.Mclk)
+	if len(state.ODSclk) > 0 || len(state.ODMclk) > 0 {
+		differences += compareODLevels("Overdrive GPU", sclkLevels(bios), state.ODSclk)
+		differences += compareODLevels("Overdrive memory", mclkLevels(bios), state.ODMclk)
+	}
+
+	displayLiveHeader("Limits")
+	if state.ODSclkMax != 0 {
+		differences += compareValue("Max GPU freq (Mhz)", uint64(bios.AtomPowerplayTable.MaxODEngineClock/100), uint64(state.ODSclkMax))
+	}
+	if state.ODMclkMax != 0 {
+		differences += compareValue("Max memory freq (Mhz)", uint64(bios.AtomPowerplayTable.MaxODMemoryClock/100), uint64(state.ODMclkMax))
+	}
+	if state.PowerCap != 0 {
+		differences += compareValue("Power cap (W)", uint64(powerLimit(bios)), uint64(state.PowerCap))
+	}
+	if state.PowerCapMax != 0 {
+		differences += compareValue("Max power cap (W)", uint64(maxPowerLimit(bios)), uint64(state.PowerCapMax))
+	}
+
+	displayLiveHeader("Fan")
+	if state.FanMaxRPM != 0 {
+		differences += compareValue("Max RPM", uint64(fanMaxRPM(bios)), state.FanMaxRPM)
+	}
+	switch state.FanMode {
+	case 2:
+		fmt.Printf("%s%s%s%s%s\n", chalk.Bold, "Fan control: ", chalk.White, "automatic, the fan table applies", chalk.Reset)
+	case 1:
+		differences++
+		fmt.Printf("%s%s%s%s%s\n", chalk.Bold, "Fan control: ", chalk.Yellow, "manual, the fan table is overridden", chalk.Reset)
+	case 0:
+		differences++
+		fmt.Printf("%s%s%s%s%s\n", chalk.Bold, "Fan control: ", chalk.Yellow, "disabled, the fan runs at full speed", chalk.Reset)
+	}
+
+	fmt.Println()
+	for _, missing := range state.Missing {
+		fmt.Println(chalk.Yellow, "Not provided by the driver:", missing, chalk.Reset)
+	}
+	if differences == 0 {
+		fmt.Printf("%s%s%s\n", chalk.Green, "The driver matches the ROM.", chalk.Reset)
+	} else {
+		fmt.Printf("%s%s%d%s\n", chalk.Yellow, "Differences: ", differences, chalk.Reset)
+	}
+}
+
+func displayLiveHeader(title string) {
+	fmt.Printf("\n%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	fmt.Printf("%s%s%s\n", chalk.Blue, title, chalk.Reset)
+	fmt.Printf("%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+}
+
+// compareValue prints a ROM value next to the live value and returns 1 when
+// they differ.
+func compareValue(label string, rom uint64, live uint64) int {
+	if rom == live {
+		fmt.Printf("%s%s: %s%d%s\n", chalk.Bold, label, chalk.White, live, chalk.Reset)
+		return 0
+	}
+	fmt.Printf("%s%s: %sROM %d, driver %d%s\n", chalk.Bold, label, chalk.Yellow, rom, live, chalk.Reset)
+	return 1
+}
+
+func compareLevels(title string, rom []DPMLevel, live []uint32) int {
+	displayLiveHeader(title)
+	if len(live) == 0 {
+		return 0
+	}
+	differences := 0
+	for i := 0; i < len(rom) || i < len(live); i++ {
+		label := fmt.Sprintf("Level %d (Mhz)", i)
+		switch {
+		case i >= len(live):
+			differences++
+			fmt.Printf("%s%s: %sROM %d, not used by the driver%s\n", chalk.Bold, label, chalk.Yellow, rom[i].Clock, chalk.Reset)
+		case i >= len(rom):
+			differences++
+			fmt.Printf("%s%s: %snot in the ROM, driver %d%s\n", chalk.Bold, label, chalk.Yellow, live[i], chalk.Reset)
+		default:
+			differences += compareValue(label, uint64(rom[i].Clock), uint64(live[i]))
+		}
+	}
+	return differences
+}
+
+// compareODLevels compares the overdrive levels, voltages are only compared
+// when the ROM has a fixed voltage for the level.
+func compareODLevels(title string, rom []DPMLevel, live []DPMLevel) int {
+	displayLiveHeader(title)
+	differences := 0
+	for i := 0; i < len(rom) || i < len(live); i++ {
+		label := fmt.Sprintf("Level %d", i)
+		switch {
+		case i >= len(live):
+			differences++
+			fmt.Printf("%s%s: %sROM %d Mhz, not used by the driver%s\n", chalk.Bold, label, chalk.Yellow, rom[i].Clock, chalk.Reset)
+		case i >= len(rom):
+			differences++
+			fmt.Printf("%s%s: %snot in the ROM, driver %d Mhz %d mV%s\n", chalk.Bold, label, chalk.Yellow, live[i].Clock, live[i].Voltage, chalk.Reset)
+		default:
+			differences += compareValue(label+" (Mhz)", uint64(rom[i].Clock), uint64(live[i].Clock))
+			if rom[i].Voltage != 0 {
+				differences += compareValue(label+" (mV)", uint64(rom[i].Voltage), uint64(live[i].Voltage))
+			}
+		}
+	}
+	return differences
+}
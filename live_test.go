@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadLiveState(t *testing.T) {
+	f := newFakeSysfs(t)
+	defer os.RemoveAll(f.root)
+	device := filepath.Join(sysfsDRMPath, "card0", "device")
+	f.write(filepath.Join(device, "pp_dpm_sclk"), "0: 300Mhz\n1: 1366Mhz *\n")
+	f.write(filepath.Join(device, "pp_dpm_mclk"), "0: 300Mhz\n1: 2000Mhz *\n")
+	f.write(filepath.Join(device, "pp_od_clk_voltage"),
+		"OD_SCLK:\n0: 300MHz 750mV\n1: 1366MHz 1150mV\nOD_MCLK:\n0: 300MHz 750mV\n1: 2000MHz 950mV\n"+
+			"OD_RANGE:\nSCLK: 300MHz 2000MHz\nMCLK: 300MHz 2250MHz\n")
+	f.write(filepath.Join(device, "hwmon", "hwmon0", "power1_cap"), "145000000\n")
+	f.write(filepath.Join(device, "hwmon", "hwmon0", "pwm1_enable"), "2\n")
+
+	state, err := readLiveState(f.root, "card0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(state.Sclk, []uint32{300, 1366}) || !reflect.DeepEqual(state.Mclk, []uint32{300, 2000}) {
+		t.Errorf("clocks are %v and %v", state.Sclk, state.Mclk)
+	}
+	if len(state.ODSclk) != 2 || state.ODSclk[1] != (DPMLevel{Clock: 1366, Voltage: 1150}) {
+		t.Errorf("OD_SCLK is %v", state.ODSclk)
+	}
+	if state.ODSclkMax != 2000 || state.ODMclkMax != 2250 {
+		t.Errorf("OD_RANGE is %d and %d", state.ODSclkMax, state.ODMclkMax)
+	}
+	if state.PowerCap != 145 || state.FanMode != 2 {
+		t.Errorf("power cap is %d, fan mode %d", state.PowerCap, state.FanMode)
+	}
+	expected := []string{"power1_cap_max", "fan1_max"}
+	if !reflect.DeepEqual(state.Missing, expected) {
+		t.Errorf("missing files are %v, expected %v", state.Missing, expected)
+	}
+
+	if _, err := readLiveState(f.root, "card1"); err == nil {
+		t.Error("a missing card was read")
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"os"
 	"fmt"
+	"strconv"
+	"strings"
 	"github.com/alecthomas/kingpin"
 	"github.com/ttacon/chalk"
 )
@@ -10,23 +12,247 @@ import (
 var (
 	app 	= kingpin.New("atitool", "A command-line tool for dealing with Radeon GPU bios files.")
 	show 	= app.Command("show", "Show values from the specified bios file.")
-	file 	= show.Arg("file", "Bios file to open.").Required().String()
+	file 	= show.Arg("file", "Bios file to open.").String()
+	showPPTable 	= show.Flag("pptable", "Decode the file as a bare PowerPlay table, such as the amdgpu pp_table.").Bool()
+	showDevice 		= show.Flag("device", "Read the bios of a PCI device, e.g. 0000:03:00.0.").String()
+	showAllDevices 	= show.Flag("all-devices", "Read the bios of every AMD display device.").Bool()
+	sysfsRoot 		= app.Flag("sysfs-root", "Root of the sysfs tree.").Default("/sys").String()
+
+	execCmd 		= app.Command("exec", "Execute a command table against a simulated register file and print the register writes.")
+	execFile 		= execCmd.Arg("file", "Bios file to open.").Required().String()
+	execTable 		= execCmd.Arg("table", "Command table name or index, e.g. SetEngineClock.").Required().String()
+	execParams 		= execCmd.Flag("param", "Parameter space dword, repeat for consecutive parameters.").Strings()
+	execRegs 		= execCmd.Flag("reg", "Preload an MMIO register, e.g. 0x0a80=0x1.").Strings()
+	execPLL 		= execCmd.Flag("pll", "Preload a PLL register, e.g. 0x10=0x1.").Strings()
+	execMC 			= execCmd.Flag("mc", "Preload an MC register, e.g. 0x10=0x1.").Strings()
+	execReads 		= execCmd.Flag("trace-reads", "Include register reads in the trace.").Bool()
+
+	liveCmd 		= app.Command("live-compare", "Compare the DPM state of a running amdgpu card against a bios file.")
+	liveCard 		= liveCmd.Flag("card", "DRM card to read, e.g. card0.").Default("card0").String()
+	liveFile 		= liveCmd.Arg("file", "Bios file to open.").Required().String()
+
+	odCmd 			= app.Command("od-script", "Write a shell script that applies the clock levels of a bios file through pp_od_clk_voltage.")
+	odCard 			= odCmd.Flag("card", "DRM card the script applies to, e.g. card0.").Default("card0").String()
+	odFile 			= odCmd.Arg("file", "Bios or PowerPlay table file to open.").Required().String()
+	odEdits 		= odCmd.Flag("edits", "YAML file with field edits.").String()
+	odSets 			= odCmd.Flag("set", "Field edit, e.g. sclk.Entries[7].Sclk=140000.").Strings()
+
+	fanCmd 			= app.Command("fan", "Edit the fan table.")
+	fanSet 			= fanCmd.Command("set", "Set fan table values and write a new file.")
+	fanSetFile 		= fanSet.Arg("file", "Bios or PowerPlay table file to open.").Required().String()
+	fanSetOut 		= fanSet.Arg("out", "File to write.").Required().String()
+	fanSetFields 	= registerFieldFlags(fanSet, "FanTable", fanFields)
+
+	powertuneCmd 	= app.Command("powertune", "Edit the powertune table.")
+	powertuneSet 	= powertuneCmd.Command("set", "Set powertune limits and write a new file.")
+	powertuneSetFile = powertuneSet.Arg("file", "Bios or PowerPlay table file to open.").Required().String()
+	powertuneSetOut = powertuneSet.Arg("out", "File to write.").Required().String()
+	powertuneSetFields = registerFieldFlags(powertuneSet, "PowerTuneTable", powertuneFields)
+
+	powerplayCmd 	= app.Command("powerplay", "Edit the PowerPlay table.")
+	powerplaySet 	= powerplayCmd.Command("set", "Set PowerPlay values and write a new file.")
+	powerplaySetFile = powerplaySet.Arg("file", "Bios or PowerPlay table file to open.").Required().String()
+	powerplaySetOut = powerplaySet.Arg("out", "File to write.").Required().String()
+	powerplaySetFields = registerFieldFlags(powerplaySet, "PowerPlayInfo", powerplayFields)
+
+	dpmCmd 			= app.Command("dpm", "Edit the GPU and memory clock levels.")
+	dpmSet 			= dpmCmd.Command("set", "Set clock levels and write a new file.")
+	dpmSetFile 		= dpmSet.Arg("file", "Bios or PowerPlay table file to open.").Required().String()
+	dpmSetOut 		= dpmSet.Arg("out", "File to write.").Required().String()
+	dpmSetFields 	= registerDPMFlags(dpmSet, dpmFields)
+
+	voltageCmd 			= app.Command("voltage", "Edit the voltages of the GPU clock levels.")
+	voltageSet 			= voltageCmd.Command("set", "Set level voltages in mV and write a new file.")
+	voltageSetFile 		= voltageSet.Arg("file", "Bios or PowerPlay table file to open.").Required().String()
+	voltageSetOut 		= voltageSet.Arg("out", "File to write.").Required().String()
+	voltageSetLevels 	= voltageSet.Flag("level", "Voltage of a GPU clock level, e.g. 7=1100mV.").StringMap()
+	voltageSetEVV 		= voltageSet.Flag("replace-evv", "Replace virtual (EVV) voltages with fixed ones.").Bool()
+	voltageSetForce 	= voltageSet.Flag("force", "Set voltages when the bios has no VDDC range to check them against.").Bool()
+
+	mcCmd 				= app.Command("mc", "Work with the memory controller register tables of the bios.")
+	mcShow 				= mcCmd.Command("show", "Show the register tables with named registers.")
+	mcShowFile 			= mcShow.Arg("file", "Bios file to open.").Required().String()
+	mcShowTable 		= mcShow.Flag("table", "Only show a table: memadjust, mcpertile, mcphyinit, dramremap, mcinit or training.").String()
+	mcSet 				= mcCmd.Command("set", "Set register values and write a new file.")
+	mcSetFile 			= mcSet.Arg("file", "Bios file to open.").Required().String()
+	mcSetOut 			= mcSet.Arg("out", "File to write.").Required().String()
+	mcSetEdits 			= mcSet.Flag("edits", "YAML file with field edits.").String()
+	mcSetSets 			= mcSet.Flag("set", "Field edit, e.g. memadjust.Blocks[0].Data[3]=0x1234.").Strings()
+
+	timingsCmd 				= app.Command("timings", "Work with the memory timing straps of the bios.")
+	timingsTransplant 		= timingsCmd.Command("transplant", "Copy timing straps to a module and write a new file.")
+	timingsTransplantFile 	= timingsTransplant.Arg("file", "Bios file to open.").Required().String()
+	timingsTransplantOut 	= timingsTransplant.Arg("out", "File to write.").Required().String()
+	timingsTransplantFrom 	= timingsTransplant.Flag("from-module", "Module to copy the straps from.").Default("-1").Int()
+	timingsTransplantTo 	= timingsTransplant.Flag("to-module", "Module to copy the straps to.").Required().Int()
+	timingsTransplantRanges = timingsTransplant.Flag("ranges", "Memory clocks of the straps in Mhz, e.g. 1500-2000. All straps by default.").Strings()
+	timingsTransplantRom 	= timingsTransplant.Flag("from-rom", "Bios file to copy the straps from, the module with the same part number is used by default.").String()
+	timingsTransplantForce 	= timingsTransplant.Flag("force", "Write straps that break GDDR5 timing rules.").Bool()
+	timingsPresets 			= timingsCmd.Command("presets", "List the strap presets.")
+	timingsPresetsDir 		= timingsPresets.Flag("presets-dir", "Directory with more .preset files.").Envar("ATITOOL_PRESETS").String()
+	timingsApplyPreset 		= timingsCmd.Command("apply-preset", "Apply a strap preset and write a new file.")
+	timingsApplyPresetFile 	= timingsApplyPreset.Arg("file", "Bios file to open.").Required().String()
+	timingsApplyPresetOut 	= timingsApplyPreset.Arg("out", "File to write.").Required().String()
+	timingsApplyPresetName 	= timingsApplyPreset.Arg("name", "Preset to apply.").Required().String()
+	timingsApplyPresetModule = timingsApplyPreset.Flag("module", "Only apply the preset to a module.").Default("-1").Int()
+	timingsApplyPresetDir 	= timingsApplyPreset.Flag("presets-dir", "Directory with more .preset files.").Envar("ATITOOL_PRESETS").String()
+	timingsApplyPresetForce = timingsApplyPreset.Flag("force", "Write straps that break GDDR5 timing rules.").Bool()
+	timingsValidate 		= timingsCmd.Command("validate", "Check the straps against GDDR5 timing rules.")
+	timingsValidateFile 	= timingsValidate.Arg("file", "Bios file to open.").Required().String()
+	timingsMatrix 			= timingsCmd.Command("matrix", "Show the key timings of every strap.")
+	timingsMatrixFile 		= timingsMatrix.Arg("file", "Bios file to open.").Required().String()
+	timingsMatrixAgainst 	= timingsMatrix.Flag("against", "Bios file to compare the timings with.").String()
+	timingsNormalize 		= timingsCmd.Command("normalize", "Sort the straps by module and clock and write a new file.")
+	timingsNormalizeFile 	= timingsNormalize.Arg("file", "Bios file to open.").Required().String()
+	timingsNormalizeOut 	= timingsNormalize.Arg("out", "File to write.").Required().String()
+	timingsNormalizeMerge 	= timingsNormalize.Flag("merge", "Remove straps with the timings of the next strap.").Bool()
+	timingsNormalizeForce 	= timingsNormalize.Flag("force", "Write straps that break GDDR5 timing rules.").Bool()
+
+	memclockCmd 			= app.Command("memclock", "Change the memory clock limits.")
+	memclockRaise 			= memclockCmd.Command("raise", "Raise the memory overdrive limit and the straps with it and write a new file.")
+	memclockRaiseFile 		= memclockRaise.Arg("file", "Bios file to open.").Required().String()
+	memclockRaiseOut 		= memclockRaise.Arg("out", "File to write.").Required().String()
+	memclockRaiseMax 		= memclockRaise.Flag("max", "New highest memory clock in Mhz.").Required().Uint32()
+	memclockRaiseForce 		= memclockRaise.Flag("force", "Write straps that break GDDR5 timing rules.").Bool()
+
+	pptableCmd 			= app.Command("pptable", "Work with PowerPlay tables as used by the Linux amdgpu pp_table file.")
+	pptableExtract 		= pptableCmd.Command("extract", "Write the PowerPlay table of a bios file to a pp_table file.")
+	pptableExtractFile 	= pptableExtract.Arg("file", "Bios file to open.").Required().String()
+	pptableExtractOut 	= pptableExtract.Arg("out", "PowerPlay table file to write.").Required().String()
+	pptableEdit 		= pptableCmd.Command("apply-edits", "Apply field edits to a pp_table file.")
+	pptableEditFile 	= pptableEdit.Arg("file", "PowerPlay table file to open.").Required().String()
+	pptableEditOut 		= pptableEdit.Arg("out", "PowerPlay table file to write.").Required().String()
+	pptableEditYAML 	= pptableEdit.Flag("edits", "YAML file with field edits.").String()
+	pptableEditSets 	= pptableEdit.Flag("set", "Field edit, e.g. powertune.TDP=120.").Strings()
+	pptableRegExport 	= pptableCmd.Command("reg-export", "Write the PowerPlay table as a Windows PP_PhmSoftPowerPlayTable .reg file.")
+	pptableRegExportFile = pptableRegExport.Arg("file", "Bios or PowerPlay table file to open.").Required().String()
+	pptableRegClassKey 	= pptableRegExport.Flag("class-key", "Display adapter class key, e.g. 0000.").Default("0000").String()
+	pptableRegImport 	= pptableCmd.Command("reg-import", "Decode the PowerPlay table of a PP_PhmSoftPowerPlayTable .reg file.")
+	pptableRegImportFile = pptableRegImport.Arg("file", ".reg file to open.").Required().String()
+	pptableRegImportOut = pptableRegImport.Flag("out", "PowerPlay table file to write.").String()
 
 	VALID_BIOS_FILESIZE 	int64 	= 524288
 	ROM_CHECKSUM_OFFSET 	int32 	= 0x21
 	ROM_HEADER_PTR 			int32 	= 0x48
 	VRAM_ENTRIES_COUNT		int		= 0
-	hasUnknownIds 			bool 	= false
 )
 
 func main() {
 	switch kingpin.MustParse(app.Parse(os.Args[1:])) {
 	case show.FullCommand():
-		openFile(*file)
+		switch {
+		case *showAllDevices:
+			openAllDevices(*sysfsRoot)
+		case *showDevice != "":
+			openDevice(*sysfsRoot, *showDevice)
+		case *file != "":
+			openFile(*file)
+		default:
+			fmt.Println(chalk.Red, "A bios file, --device or --all-devices is required.", chalk.Reset)
+			os.Exit(1)
+		}
+	case execCmd.FullCommand():
+		runCommandTable(*execFile, *execTable)
+	case liveCmd.FullCommand():
+		liveCompare(*sysfsRoot, *liveCard, *liveFile)
+	case odCmd.FullCommand():
+		odScript(*odFile, *odCard, *odEdits, *odSets)
+	case fanSet.FullCommand():
+		setFields(*fanSetFile, *fanSetOut, fanSetFields, validateFan)
+	case powertuneSet.FullCommand():
+		setFields(*powertuneSetFile, *powertuneSetOut, powertuneSetFields, validatePowertune)
+	case powerplaySet.FullCommand():
+		setFields(*powerplaySetFile, *powerplaySetOut, powerplaySetFields, validatePowerplay)
+	case dpmSet.FullCommand():
+		setFields(*dpmSetFile, *dpmSetOut, dpmSetFields, validateDPM)
+	case voltageSet.FullCommand():
+		setVoltage(*voltageSetFile, *voltageSetOut, *voltageSetLevels, *voltageSetEVV, *voltageSetForce)
+	case mcShow.FullCommand():
+		showMCRegisters(*mcShowFile, *mcShowTable)
+	case mcSet.FullCommand():
+		setMCRegisters(*mcSetFile, *mcSetOut, *mcSetEdits, *mcSetSets)
+	case timingsTransplant.FullCommand():
+		transplantTimings(*timingsTransplantFile, *timingsTransplantOut, *timingsTransplantFrom, *timingsTransplantTo, *timingsTransplantRanges, *timingsTransplantRom, *timingsTransplantForce)
+	case timingsPresets.FullCommand():
+		listPresets(*timingsPresetsDir)
+	case timingsApplyPreset.FullCommand():
+		applyPresetFile(*timingsApplyPresetFile, *timingsApplyPresetOut, *timingsApplyPresetName, *timingsApplyPresetModule, *timingsApplyPresetDir, *timingsApplyPresetForce)
+	case timingsValidate.FullCommand():
+		validateTimings(*timingsValidateFile)
+	case timingsMatrix.FullCommand():
+		displayTimingMatrix(*timingsMatrixFile, *timingsMatrixAgainst)
+	case timingsNormalize.FullCommand():
+		normalizeTimings(*timingsNormalizeFile, *timingsNormalizeOut, *timingsNormalizeMerge, *timingsNormalizeForce)
+	case memclockRaise.FullCommand():
+		raiseMemoryClock(*memclockRaiseFile, *memclockRaiseOut, *memclockRaiseMax, *memclockRaiseForce)
+	case pptableExtract.FullCommand():
+		extractPowerplay(*pptableExtractFile, *pptableExtractOut)
+	case pptableEdit.FullCommand():
+		applyPowerplayEdits(*pptableEditFile, *pptableEditOut, *pptableEditYAML, *pptableEditSets)
+	case pptableRegExport.FullCommand():
+		exportRegFile(*pptableRegExportFile, *pptableRegClassKey)
+	case pptableRegImport.FullCommand():
+		importRegFile(*pptableRegImportFile, *pptableRegImportOut)
 	}
 }
 
 func openFile(filename string) {
+	buffer := readFile(filename)
+	if *showPPTable || isPowerplayBlob(buffer) {
+		openPowerplay(buffer)
+		return
+	}
+	warnRomSize(buffer)
+	openRom(buffer)
+}
+
+func openRom(buffer []byte) {
+	resetDiagnostics()
+	displayBios(unpackData(buffer))
+}
+
+func displayBios(bios Bios) {
+	displayRom(bios)
+	displayPowerplayTables(bios)
+	displayVRAM(bios)
+
+	fmt.Println()
+
+	displayDiagnostics()
+}
+
+// openPowerplay shows a bare PowerPlay table. It has no ROM header and no VRAM
+// info, only the PowerPlay views are shown.
+func openPowerplay(buffer []byte) {
+	resetDiagnostics()
+	bios := unpackPowerplay(buffer)
+	if _, found := bios.Tables["PowerPlayInfo"]; !found {
+		fmt.Println(chalk.Red, "No PowerPlay table found.", chalk.Reset)
+		os.Exit(1)
+	}
+	displayPPTable(bios, len(buffer))
+	displayPowerplayTables(bios)
+
+	fmt.Println()
+
+	displayDiagnostics()
+}
+
+func displayPowerplayTables(bios Bios) {
+	displayPowerplay(bios)
+	if bios.Family == FamilyVega10 {
+		displayVega10Powertune(bios)
+		displayVega10Fan(bios)
+		displayVega10Clocks(bios)
+	} else {
+		displayPowertune(bios)
+		displayFan(bios)
+		displayGPU(bios)
+		displayMemory(bios)
+	}
+}
+
+func readFile(filename string) []byte {
 	file, err := os.Open( filename )
 	if err != nil {
 		fmt.Println(chalk.Red, err, chalk.Reset)
@@ -37,9 +263,6 @@ func openFile(filename string) {
 		fmt.Println(chalk.Red, err, chalk.Reset)
 		os.Exit(1)
 	}
-	if fi.Size() < VALID_BIOS_FILESIZE {
-		fmt.Println(chalk.Red, "This BIOS is less than the standard 512KB size.\nFlashing this BIOS may corrupt your graphics card.", chalk.Reset)
-	}
 
 	buffer := make([]byte, fi.Size())
 	_, err = file.Read(buffer)
@@ -47,27 +270,68 @@ func openFile(filename string) {
 		fmt.Println(chalk.Red, "Unable to read ", filename, chalk.Reset)
 		os.Exit(1)
 	}
+	return buffer
+}
 
-	bios := unpackData(buffer)
-	displayRom(bios)
-	displayPowerplay(bios)
-	displayPowertune(bios)
-	displayFan(bios)
-	displayGPU(bios)
-	//displayMemory(bios) // Crashes with panic: runtime error: index out of range
-	displayVRAM(bios)
+func warnRomSize(buffer []byte) {
+	if int64(len(buffer)) < VALID_BIOS_FILESIZE {
+		fmt.Println(chalk.Red, "This BIOS is less than the standard 512KB size.\nFlashing this BIOS may corrupt your graphics card.", chalk.Reset)
+	}
+}
 
-	fmt.Println()
+func runCommandTable(filename string, table string) {
+	buffer := readFile(filename)
+	warnRomSize(buffer)
+
+	index, err := commandTableIndex(table)
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+
+	params := make([]uint32, AtomMaxParameters)
+	for i, param := range *execParams {
+		value, err := strconv.ParseUint(param, 0, 32)
+		if err != nil || i >= len(params) {
+			fmt.Println(chalk.Red, "Invalid parameter ", param, chalk.Reset)
+			os.Exit(1)
+		}
+		params[i] = uint32(value)
+	}
+
+	regs := NewRegisterFile()
+	regs.TraceReads = *execReads
+	for space, assignments := range map[string][]string{"MMIO": *execRegs, "PLL": *execPLL, "MC": *execMC} {
+		if err := regs.Preload(space, assignments); err != nil {
+			fmt.Println(chalk.Red, err, chalk.Reset)
+			os.Exit(1)
+		}
+	}
+
+	err = executeCommandTable(buffer, index, params, regs)
+	displayTrace(commandTableNames[index], regs)
+	if err != nil {
+		fmt.Println(chalk.Red, "Execution aborted: ", err, chalk.Reset)
+		os.Exit(1)
+	}
 
-	if hasUnknownIds {
-		fmt.Println(chalk.Yellow, "Detected unsupported data. Please report your results and GPU model so we can add it.", chalk.Reset)
+	fmt.Printf("%s%s%s", chalk.Bold, "Parameters: ", chalk.White)
+	for i := 0; i < len(*execParams) || i < 1; i++ {
+		fmt.Printf("0x%08x ", params[i])
 	}
+	fmt.Printf("%s\n", chalk.Reset)
 }
 
 func displayRom(bios Bios) {
 	fmt.Printf("\n%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
 	fmt.Printf("%s%s%s\n", chalk.Blue, "ROM", chalk.Reset)
 	fmt.Printf("%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	fmt.Printf("%s%s%s%s%s\n", chalk.Bold, "VendorID: ", chalk.White,
+		displayRomVendorId(bios.PCIRHeader.VendorID), chalk.Reset)
+	fmt.Printf("%s%s%s%s%s\n", chalk.Bold, "DeviceID: ", chalk.White,
+		displayRomDeviceId(bios.PCIRHeader.DeviceID), chalk.Reset)
+	fmt.Printf("%s%s%s%s%s\n", chalk.Bold, "Family: ", chalk.White,
+		bios.Family, chalk.Reset)
 	fmt.Printf("%s%s%s0x%x%s\n", chalk.Bold, "SubID: ", chalk.White,
 		bios.AtomRomHeader.SubsystemID, chalk.Reset)
 	fmt.Printf("%s%s%s%s%s\n", chalk.Bold, "SubVendorID: ", chalk.White,
@@ -76,10 +340,24 @@ func displayRom(bios Bios) {
 		bios.AtomRomHeader.FirmWareSignature, chalk.Reset)
 }
 
+func displayPPTable(bios Bios, size int) {
+	fmt.Printf("\n%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	fmt.Printf("%s%s%s\n", chalk.Blue, "PowerPlay table", chalk.Reset)
+	fmt.Printf("%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Size (bytes): ", chalk.White,
+		size, chalk.Reset)
+	fmt.Printf("%s%s%s%s%s\n", chalk.Bold, "Family: ", chalk.White,
+		bios.Family, chalk.Reset)
+}
+
 func displayPowerplay(bios Bios) {
 	fmt.Printf("\n%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
 	fmt.Printf("%s%s%s\n", chalk.Blue, "Powerplay",  chalk.Reset)
 	fmt.Printf("%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	if table, found := bios.Tables["PowerPlayInfo"]; found {
+		fmt.Printf("%s%s%s%s (%s)%s\n", chalk.Bold, "Revision: ", chalk.White,
+			table.Revision, table.Layout, chalk.Reset)
+	}
 	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Max GPU freq (Mhz): ", chalk.White,
 		bios.AtomPowerplayTable.MaxODEngineClock / 100, chalk.Reset)
 	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Max memory freq (Mhz): ", chalk.White,
@@ -102,8 +380,11 @@ func displayPowertune(bios Bios) {
 		bios.AtomPowertuneTable.TjMax, chalk.Reset)
 	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Shutdown Temp. (C): ", chalk.White,
 		bios.AtomPowertuneTable.SoftwareShutdownTemp, chalk.Reset)
-	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Hotspot Temp. (C): ", chalk.White,
-		bios.AtomPowertuneTable.TemperatureLimitHotspot, chalk.Reset)
+	// Tonga has no temperature limits beyond TjMax.
+	if bios.Tables["PowerTuneTable"].Layout != "Tonga" {
+		fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Hotspot Temp. (C): ", chalk.White,
+			bios.AtomPowertuneTable.TemperatureLimitHotspot, chalk.Reset)
+	}
 }
 
 func displayFan(bios Bios) {
@@ -136,6 +417,10 @@ func displayFan(bios Bios) {
 		bios.AtomFanTable.FanOutputSensitivity, chalk.Reset)
 	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Acoustic Limit (MHz): ", chalk.White,
 		bios.AtomFanTable.MinFanSCLKAcousticLimit / 100, chalk.Reset)
+	if bios.Family == FamilyFiji {
+		fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "HBM Gain: ", chalk.White,
+			bios.AtomFanTable.FanGainHbm, chalk.Reset)
+	}
 }
 
 func displayGPU(bios Bios) {
@@ -146,25 +431,143 @@ func displayGPU(bios Bios) {
 	count := int(bios.AtomSClkTable.NumEntries)
 	for i := 0; i < count; i++ {
 		index := int(bios.AtomSClkTable.Entries[i].VddInd)
+		if index >= len(bios.AtomVoltageTable.Entries) {
+			addDiagnostic("GPU level voltage index", "%d of level %d", index, i)
+			continue
+		}
 		fmt.Printf("%s%d %s: %s%d %s%s\n", chalk.Bold, bios.AtomSClkTable.Entries[i].Sclk / 100, "Mhz", chalk.White,
 			bios.AtomVoltageTable.Entries[index].Vdd, "mV", chalk.Reset)
 	}
 }
 
+func displayVega10Powertune(bios Bios) {
+	fmt.Printf("\n%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	fmt.Printf("%s%s%s\n", chalk.Blue, "Powertune", chalk.Reset)
+	fmt.Printf("%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Socket Power Limit (W): ", chalk.White,
+		bios.AtomVega10PowertuneTable.SocketPowerLimit, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "TDC (A): ", chalk.White,
+		bios.AtomVega10PowertuneTable.TdcLimit, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "EDC (A): ", chalk.White,
+		bios.AtomVega10PowertuneTable.EdcLimit, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Shutdown Temp. (C): ", chalk.White,
+		bios.AtomVega10PowertuneTable.SoftwareShutdownTemp, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Edge Temp. (C): ", chalk.White,
+		bios.AtomVega10PowertuneTable.TemperatureLimitTedge, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Hotspot Temp. (C): ", chalk.White,
+		bios.AtomVega10PowertuneTable.TemperatureLimitHotSpot, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "HBM Temp. (C): ", chalk.White,
+		bios.AtomVega10PowertuneTable.TemperatureLimitHBM, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "VR SoC Temp. (C): ", chalk.White,
+		bios.AtomVega10PowertuneTable.TemperatureLimitVrSoc, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "VR Mem Temp. (C): ", chalk.White,
+		bios.AtomVega10PowertuneTable.TemperatureLimitVrMem, chalk.Reset)
+}
+
+func displayVega10Fan(bios Bios) {
+	fmt.Printf("\n%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	fmt.Printf("%s%s%s\n", chalk.Blue, "Fan", chalk.Reset)
+	fmt.Printf("%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Target Temp. (C): ", chalk.White,
+		bios.AtomVega10FanTable.TargetTemperature, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Min PWM (%): ", chalk.White,
+		bios.AtomVega10FanTable.MinimumPWMLimit, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Acoustic Limit (RPM): ", chalk.White,
+		bios.AtomVega10FanTable.FanAcousticLimitRpm, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Throttling RPM: ", chalk.White,
+		bios.AtomVega10FanTable.ThrottlingRPM, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Target GFX clock (MHz): ", chalk.White,
+		bios.AtomVega10FanTable.TargetGfxClk / 100, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Sensitivity: ", chalk.White,
+		bios.AtomVega10FanTable.FanOutputSensitivity, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Zero RPM: ", chalk.White,
+		bios.AtomVega10FanTable.EnableZeroRPM, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Stop Temp. (C): ", chalk.White,
+		bios.AtomVega10FanTable.FanStopTemperature, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Start Temp. (C): ", chalk.White,
+		bios.AtomVega10FanTable.FanStartTemperature, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "HBM Gain: ", chalk.White,
+		bios.AtomVega10FanTable.FanGainHbm, chalk.Reset)
+}
+
+func displayVega10Clocks(bios Bios) {
+	gfxclk := make([]AtomVega10ClkEntry, len(bios.AtomVega10GfxClkTable.Entries))
+	for i, entry := range bios.AtomVega10GfxClkTable.Entries {
+		gfxclk[i] = AtomVega10ClkEntry{entry.Clk, entry.VddInd}
+	}
+	mclk := make([]AtomVega10ClkEntry, len(bios.AtomVega10MClkTable.Entries))
+	for i, entry := range bios.AtomVega10MClkTable.Entries {
+		mclk[i] = AtomVega10ClkEntry{entry.MemClk, entry.VddInd}
+	}
+
+	displayVega10ClockTable(bios, "GPU", gfxclk)
+	displayVega10ClockTable(bios, "SoC", bios.AtomVega10SocClkTable.Entries)
+	displayVega10ClockTable(bios, "Memory", mclk)
+	displayVega10ClockTable(bios, "DCEF", bios.AtomVega10DcefClkTable.Entries)
+}
+
+func displayVega10ClockTable(bios Bios, title string, entries []AtomVega10ClkEntry) {
+	fmt.Printf("\n%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	fmt.Printf("%s%s%s\n", chalk.Blue, title, chalk.Reset)
+	fmt.Printf("%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+
+	voltages := bios.AtomVega10VddcTable.Entries
+	for _, entry := range entries {
+		index := int(entry.VddInd)
+		if index >= len(voltages) {
+			addDiagnostic("Clock level voltage index", "%d of %d Mhz", index, entry.Clk / 100)
+			continue
+		}
+		fmt.Printf("%s%d %s: %s%d %s%s\n", chalk.Bold, entry.Clk / 100, "Mhz", chalk.White,
+			voltages[index].Vdd, "mV", chalk.Reset)
+	}
+}
+
 func displayMemory(bios Bios) {
 	fmt.Printf("\n%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
 	fmt.Printf("%s%s%s\n", chalk.Blue, "Memory", chalk.Reset)
 	fmt.Printf("%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
 
 	count := int(bios.AtomMClkTable.NumEntries)
-	fmt.Println(count)
 	for i := 0; i < count; i++ {
-		index := int(bios.AtomMClkTable.Entries[i].Mclk)
-		fmt.Printf("%s%d %s: %s%d %s%s\n", chalk.Bold, bios.AtomMClkTable.Entries[i].VddcInd / 100, "Mhz", chalk.White,
-			bios.AtomVoltageTable.Entries[index].Vdd, "mV", chalk.Reset)
+		entry := bios.AtomMClkTable.Entries[i]
+		index := int(entry.VddcInd)
+		if index >= len(bios.AtomVoltageTable.Entries) {
+			addDiagnostic("Memory level voltage index", "%d of level %d", index, i)
+			continue
+		}
+		fmt.Printf("%s%d %s: %s%s, %s %d %s, %s %d %s, %s %d %s%s\n", chalk.Bold, entry.Mclk / 100, "Mhz", chalk.White,
+			formatVoltage(bios.AtomVoltageTable.Entries[index].Vdd), "VDDCI", entry.Vddci, "mV", "MVDD", entry.Mvdd, "mV",
+			"VDDGFX offset", int16(entry.VddgfxOffset), "mV", chalk.Reset)
 	}
 }
 
+func displayTrace(table string, regs *RegisterFile) {
+	fmt.Printf("\n%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	fmt.Printf("%s%s%s\n", chalk.Blue, table, chalk.Reset)
+	fmt.Printf("%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+
+	for _, access := range regs.Trace {
+		direction := "<-"
+		if !access.Write {
+			direction = "->"
+		}
+		fmt.Printf("%s%-24s %-4s %s0x%04x %s 0x%08x%s\n", chalk.Bold, access.Table, access.Space, chalk.White,
+			access.Index, direction, access.Value, chalk.Reset)
+	}
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Register writes: ", chalk.White, countWrites(regs.Trace), chalk.Reset)
+}
+
+func countWrites(trace []RegisterAccess) int {
+	count := 0
+	for _, access := range trace {
+		if access.Write {
+			count++
+		}
+	}
+	return count
+}
+
 func displayVRAM(bios Bios) {
 	fmt.Printf("\n%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
 	fmt.Printf("%s%s%s\n", chalk.Blue, "VRAM", chalk.Reset)
@@ -177,39 +580,42 @@ func displayVRAM(bios Bios) {
 				fmt.Println()
 			}
 
+			partNumber := strings.TrimRight(bios.AtomVRAMEntry[i].MemPNString, "\x00 ")
 			fmt.Printf("%s%s: %s%s %s\n", chalk.Bold, "Part num", chalk.White,
-				string(bios.AtomVRAMEntry[i].MemPNString[:10]), chalk.Reset)
+				partNumber, chalk.Reset)
 			fmt.Printf("\t%s%s: %s%s %s\n", chalk.Bold, "VendorID", chalk.White,
 				displayVramVendorId(bios.AtomVRAMEntry[i].MemoryVenderID), chalk.Reset)
+			fmt.Printf("\t%s%s: %s%s %s\n", chalk.Bold, "Vendor revision", chalk.White,
+				displayVramVendorRevision(bios.AtomVRAMEntry[i].MemoryVenderID), chalk.Reset)
 			fmt.Printf("\t%s%s: %s%d %s\n", chalk.Bold, "Size (MB)", chalk.White,
 				bios.AtomVRAMEntry[i].MemorySize, chalk.Reset)
 			fmt.Printf("\t%s%s: %s%s %s\n", chalk.Bold, "Density", chalk.White,
 				displayVramDensity(bios.AtomVRAMEntry[i].Density), chalk.Reset)
 			fmt.Printf("\t%s%s: %s%s %s\n", chalk.Bold, "Type", chalk.White,
 				displayVramType(bios.AtomVRAMEntry[i].MemoryType), chalk.Reset)
-
-			//memoryType := "Unknown"
-			//switch bios.AtomVRAMEntry[i].MemoryType {
-			//case MemoryTypeGDDR1:
-			//	memoryType = "GDDR1"
-			//case MemoryTypeDDR2:
-			//	memoryType = "DDR2"
-			//case MemoryTypeGDDR3:
-			//	memoryType = "GDDR3"
-			//case MemoryTypeGDDR4:
-			//	memoryType = "GDDR4"
-			//case MemoryTypeGDDR5:
-			//	memoryType = "GDDR5"
-			//case MemoryTypeHBM:
-			//	memoryType = "HBM"
-			//case MemoryTypeDDR3:
-			//	memoryType = "DDR3"
-			//default:
-			//	hasUnknownIds = true
-			//	memoryType += fmt.Sprintf(" (0x%x)", bios.AtomVRAMEntry[i].MemoryType)
-			//}
-			//fmt.Printf("\t%s%s: %s%s %s\n", chalk.Bold, "Type", chalk.White,
-			//	memoryType, chalk.Reset)
+			displayPartNumber(partNumber, bios.AtomVRAMEntry[i])
 		}
 	}
 }
+
+func displayPartNumber(partNumber string, entry AtomVRAMEntry) {
+	part, found := decodePartNumber(partNumber)
+	if !found {
+		fmt.Printf("\t%s%s: %s%s %s\n", chalk.Bold, "Part", chalk.White, "Unknown part number", chalk.Reset)
+		return
+	}
+	fmt.Printf("\t%s%s: %s%s %s %d Mbit x%d %s\n", chalk.Bold, "Part", chalk.White,
+		part.Vendor, part.Type, part.Density, part.Width, chalk.Reset)
+	if part.Speed != "" {
+		fmt.Printf("\t%s%s: %s%s %s\n", chalk.Bold, "Speed grade", chalk.White, part.Speed, chalk.Reset)
+	}
+	if part.Voltage != "" {
+		fmt.Printf("\t%s%s: %s%s %s\n", chalk.Bold, "Voltage", chalk.White, part.Voltage, chalk.Reset)
+	}
+	if part.Revision != "" {
+		fmt.Printf("\t%s%s: %s%s %s\n", chalk.Bold, "Die revision", chalk.White, part.Revision, chalk.Reset)
+	}
+	for _, problem := range checkPartNumber(part, entry) {
+		fmt.Println(chalk.Yellow, "\t" + problem, chalk.Reset)
+	}
+}
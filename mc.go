@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ttacon/chalk"
+)
+
+// mcRegisters names the MMIO dword indexes of the memory controller used in the
+// VRAMInfo register tables of the GMC 7 and 8 generations (Tonga, Fiji and
+// Polaris). Registers that are not known are shown by index.
+var mcRegisters = map[uint16]string{
+	0x09dd: "MC_ARB_DRAM_TIMING",
+	0x09de: "MC_ARB_DRAM_TIMING2",
+	0x09fa: "MC_ARB_CG",
+	0x09fc: "MC_ARB_DRAM_TIMING_1",
+	0x09ff: "MC_ARB_DRAM_TIMING2_1",
+	0x0a02: "MC_ARB_BURST_TIME",
+	0x0a28: "MC_SEQ_RAS_TIMING",
+	0x0a29: "MC_SEQ_CAS_TIMING",
+	0x0a2a: "MC_SEQ_MISC_TIMING",
+	0x0a2b: "MC_SEQ_MISC_TIMING2",
+	0x0a2c: "MC_SEQ_PMG_TIMING",
+	0x0a2d: "MC_SEQ_RD_CTL_D0",
+	0x0a2e: "MC_SEQ_RD_CTL_D1",
+	0x0a2f: "MC_SEQ_WR_CTL_D0",
+	0x0a30: "MC_SEQ_WR_CTL_D1",
+	0x0a31: "MC_SEQ_CMD",
+	0x0a80: "MC_SEQ_MISC0",
+	0x0a81: "MC_SEQ_MISC1",
+	0x0a82: "MC_SEQ_RESERVE_M",
+	0x0a83: "MC_PMG_CMD_EMRS",
+	0x0a8b: "MC_SEQ_MISC3",
+	0x0a91: "MC_SEQ_IO_DEBUG_INDEX",
+	0x0a92: "MC_SEQ_IO_DEBUG_DATA",
+	0x0a95: "MC_SEQ_MISC5",
+	0x0a96: "MC_SEQ_MISC6",
+	0x0a97: "MC_SEQ_MISC8",
+	0x0a99: "MC_SEQ_MISC7",
+	0x0a9b: "MC_SEQ_RAS_TIMING_LP",
+	0x0a9c: "MC_SEQ_CAS_TIMING_LP",
+	0x0a9d: "MC_SEQ_MISC_TIMING_LP",
+	0x0a9e: "MC_SEQ_MISC_TIMING2_LP",
+	0x0a9f: "MC_SEQ_WR_CTL_D0_LP",
+	0x0aa0: "MC_SEQ_WR_CTL_D1_LP",
+	0x0aa1: "MC_SEQ_PMG_CMD_EMRS_LP",
+	0x0aa2: "MC_SEQ_PMG_CMD_MRS_LP",
+	0x0aab: "MC_PMG_CMD_MRS",
+	0x0ac7: "MC_SEQ_RD_CTL_D0_LP",
+	0x0ac8: "MC_SEQ_RD_CTL_D1_LP",
+	0x0ad1: "MC_PMG_CMD_MRS1",
+	0x0ad2: "MC_SEQ_PMG_CMD_MRS1_LP",
+	0x0ad5: "MC_SEQ_WR_CTL_2",
+	0x0ad6: "MC_SEQ_WR_CTL_2_LP",
+	0x0ad7: "MC_PMG_CMD_MRS2",
+	0x0ad8: "MC_SEQ_PMG_CMD_MRS2_LP",
+}
+
+// mcRegisterTables are the VRAMInfo register tables in the order they are
+// shown, with the short name used in edits.
+var mcRegisterTables = []struct {
+	Table string
+	Alias string
+	Title string
+}{
+	{"MemAdjust", "memadjust", "Memory adjust"},
+	{"McAdjustPerTile", "mcpertile", "MC adjust per tile"},
+	{"McPhyInit", "mcphyinit", "MC PHY init"},
+}
+
+// mcPhyRegisterCount is the number of MC_IO_DEBUG indexes the driver headers
+// name, MC_IO_DEBUG_UP_0 to MC_IO_DEBUG_UP_159.
+const mcPhyRegisterCount = 160
+
+// mcReadOnlyTables are the tables mc show lists that have no encoder, their
+// short names are not accepted in edits.
+var mcReadOnlyTables = map[string]string{
+	"mcinit":   "MCInitParameter",
+	"training": "MemoryTrainingInfo",
+}
+
+// mcTableName is tableName that also knows the read-only tables.
+func mcTableName(name string) string {
+	for alias, table := range mcReadOnlyTables {
+		if strings.EqualFold(name, alias) || strings.EqualFold(name, table) {
+			return table
+		}
+	}
+	return tableName(name)
+}
+
+// mcRegisterName names a register of a register table. PHY registers are
+// written through MC_SEQ_IO_DEBUG_INDEX and MC_SEQ_IO_DEBUG_DATA, indexes past
+// the named ones are shown as is.
+func mcRegisterName(register AtomInitRegIndex) string {
+	if register.PreRegDataLength&AtomRegAccessMCIODebug != 0 {
+		if register.RegIndex < mcPhyRegisterCount {
+			return fmt.Sprintf("MC_IO_DEBUG_UP_%d", register.RegIndex)
+		}
+		return fmt.Sprintf("MC_IO_DEBUG[0x%03x]", register.RegIndex)
+	}
+	return mcRegisterIndexName(register.RegIndex)
+}
+
+func mcRegisterIndexName(index uint16) string {
+	if name, found := mcRegisters[index]; found {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", index)
+}
+
+// mcRegisterTable returns the decoded register table by its name.
+func mcRegisterTable(bios *Bios, table string) *AtomInitRegBlock {
+	switch table {
+	case "MemAdjust":
+		return &bios.AtomMemAdjustTable
+	case "McAdjustPerTile":
+		return &bios.AtomMcAdjustPerTileTable
+	case "McPhyInit":
+		return &bios.AtomMcPhyInitTable
+	}
+	return nil
+}
+
+func formatModule(module uint32, modules []AtomVRAMEntry) string {
+	if int(module) < len(modules) {
+		return fmt.Sprintf("module %d (%s)", module, strings.TrimRight(modules[module].MemPNString, "\x00 "))
+	}
+	return fmt.Sprintf("module %d", module)
+}
+
+func formatClockRange(memoryID uint32) string {
+	clock := memoryID & AtomMemClockRangeMask
+	if clock == AtomMemClockRangeMask {
+		return "any clock"
+	}
+	return fmt.Sprintf("up to %d Mhz", clock/100)
+}
+
+func showMCRegisters(filename string, table string) {
+	buffer := readFile(filename)
+	if isPowerplayBlob(buffer) {
+		fmt.Println(chalk.Red, filename, "is a PowerPlay table, the register tables are part of the bios.", chalk.Reset)
+		os.Exit(1)
+	}
+	bios := unpackData(buffer)
+
+	shown := 0
+	for _, registerTable := range mcRegisterTables {
+		if table != "" && mcTableName(table) != registerTable.Table {
+			continue
+		}
+		if _, found := bios.Tables[registerTable.Table]; !found {
+			continue
+		}
+		displayMCRegisterTable(registerTable.Title, registerTable.Alias, *mcRegisterTable(&bios, registerTable.Table), bios.AtomVRAMEntry)
+		shown++
+	}
+	if (table == "" || mcTableName(table) == "DramDataRemap") && len(bios.AtomDramDataRemapTable.Entries) > 0 {
+		displayDramDataRemap(bios)
+		shown++
+	}
+	if (table == "" || mcTableName(table) == "MCInitParameter") && bios.Tables["MCInitParameter"].Layout != "" {
+		displayMCInitParameter(bios)
+		shown++
+	}
+	if (table == "" || mcTableName(table) == "MemoryTrainingInfo") && bios.Tables["MemoryTrainingInfo"].Layout != "" {
+		displayMemoryTraining(bios)
+		shown++
+	}
+	if shown == 0 {
+		fmt.Println(chalk.Yellow, "No register tables found.", chalk.Reset)
+	}
+}
+
+func displayMCRegisterTable(title string, alias string, table AtomInitRegBlock, modules []AtomVRAMEntry) {
+	fmt.Printf("\n%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	fmt.Printf("%s%s (%s)%s\n", chalk.Blue, title, alias, chalk.Reset)
+	fmt.Printf("%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	displayInitRegBlock(table, modules)
+}
+
+// displayInitRegBlock shows the data blocks of a register table, the module
+// of a block is named by its part number.
+func displayInitRegBlock(table AtomInitRegBlock, modules []AtomVRAMEntry) {
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Registers: ", chalk.White, len(table.Registers), chalk.Reset)
+	for i, block := range table.Blocks {
+		fmt.Printf("%sBlocks[%d]: %s%s, %s%s\n", chalk.Bold, i, chalk.White,
+			formatModule(block.MemoryID>>24, modules), formatClockRange(block.MemoryID), chalk.Reset)
+		for r, register := range table.Registers {
+			note := ""
+			if register.PreRegDataLength&AtomRegDataLengthMask == AtomRegDataSameAsPrevious {
+				note = " (same as previous)"
+			}
+			fmt.Printf("\t%sData[%d] %s: %s0x%08x%s%s\n", chalk.Bold, r, mcRegisterName(register), chalk.White,
+				block.Data[r], note, chalk.Reset)
+		}
+	}
+}
+
+func displayDramDataRemap(bios Bios) {
+	fmt.Printf("\n%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	fmt.Printf("%s%s%s\n", chalk.Blue, "DRAM data remap (dramremap)", chalk.Reset)
+	fmt.Printf("%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	for i, entry := range bios.AtomDramDataRemapTable.Entries {
+		fmt.Printf("%sEntries[%d]%s\n", chalk.Bold, i, chalk.Reset)
+		fmt.Printf("\t%s%s%s0x%08x, 0x%08x%s\n", chalk.Bold, "Byte remap: ", chalk.White,
+			entry.ByteRemapCh0, entry.ByteRemapCh1, chalk.Reset)
+		fmt.Printf("\t%s%s%s%s%s\n", chalk.Bold, "Bit remap channel 0: ", chalk.White, formatDwords(entry.BitRemapCh0[:]), chalk.Reset)
+		fmt.Printf("\t%s%s%s%s%s\n", chalk.Bold, "Bit remap channel 1: ", chalk.White, formatDwords(entry.BitRemapCh1[:]), chalk.Reset)
+	}
+}
+
+func displayMCInitParameter(bios Bios) {
+	location := bios.Tables["MCInitParameter"]
+	param := bios.MCInitParameter
+	fmt.Printf("\n%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	fmt.Printf("%s%s%s\n", chalk.Blue, "MC init parameter (mcinit)", chalk.Reset)
+	fmt.Printf("%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	fmt.Printf("%s%s%s%s%s\n", chalk.Bold, "Revision: ", chalk.White, location.Revision, chalk.Reset)
+	if location.Layout == "V1.1" {
+		for i := 0; i < len(param.ARBSEQData); i += 4 {
+			fmt.Printf("%sARB/SEQ data [%d]: %s%s%s\n", chalk.Bold, i, chalk.White, formatDwords(param.ARBSEQData[i:i+4]), chalk.Reset)
+		}
+		fmt.Printf("%s%s%s\n", chalk.Bold, "Memory type registers", chalk.Reset)
+		displayInitRegBlock(param.MemType, bios.AtomVRAMEntry)
+		fmt.Printf("%s%s%s\n", chalk.Bold, "Common registers", chalk.Reset)
+		displayInitRegBlock(param.Common, bios.AtomVRAMEntry)
+		return
+	}
+	fmt.Printf("%s%s%s0x%08x%s\n", chalk.Bold, "MC microcode version: ", chalk.White, param.MCUcodeVersion, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "MC microcode length: ", chalk.White, param.MCUcodeLength, chalk.Reset)
+	displayRegInitSettings(param.RegInit)
+}
+
+func displayMemoryTraining(bios Bios) {
+	location := bios.Tables["MemoryTrainingInfo"]
+	training := bios.MemoryTraining
+	fmt.Printf("\n%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	fmt.Printf("%s%s%s\n", chalk.Blue, "Memory training (training)", chalk.Reset)
+	fmt.Printf("%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	fmt.Printf("%s%s%s%s%s\n", chalk.Bold, "Revision: ", chalk.White, location.Revision, chalk.Reset)
+	if location.Layout == "V2.1" {
+		fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Training loops: ", chalk.White, training.TrainingLoop, chalk.Reset)
+		displayInitRegBlock(training.Settings, bios.AtomVRAMEntry)
+		return
+	}
+	fmt.Printf("%s%s%s0x%08x%s\n", chalk.Bold, "MC microcode version: ", chalk.White, training.MCUcodeVersion, chalk.Reset)
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "MC microcode length: ", chalk.White, training.MCUcodeLength, chalk.Reset)
+	displayRegInitSettings(training.IOInit)
+}
+
+func displayRegInitSettings(settings []AtomRegInitSetting) {
+	fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Registers: ", chalk.White, len(settings), chalk.Reset)
+	for _, setting := range settings {
+		fmt.Printf("\t%s%s: %s0x%08x%s\n", chalk.Bold, mcRegisterIndexName(setting.RegIndex), chalk.White, setting.RegValue, chalk.Reset)
+	}
+}
+
+func formatDwords(values []uint32) string {
+	formatted := make([]string, len(values))
+	for i, value := range values {
+		formatted[i] = fmt.Sprintf("0x%08x", value)
+	}
+	return strings.Join(formatted, " ")
+}
+
+// setMCRegisters applies field edits to the register tables of a bios file,
+// e.g. memadjust.Blocks[0].Data[3]=0x1234.
+func setMCRegisters(filename string, out string, editsFile string, sets []string) {
+	buffer := readFile(filename)
+	if isPowerplayBlob(buffer) {
+		fmt.Println(chalk.Red, filename, "is a PowerPlay table, the register tables are part of the bios.", chalk.Reset)
+		os.Exit(1)
+	}
+	bios := unpackData(buffer)
+
+	edits, err := collectEdits(editsFile, sets)
+	if err == nil && len(edits) == 0 {
+		err = fmt.Errorf("no edits given, use --edits or --set")
+	}
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+
+	changes, err := applyEdits(&bios, edits)
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	if err := saveTables(out, buffer, &bios, changedTables(changes)); err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	displayChanges(changes)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/ttacon/chalk"
+)
+
+// StrapBand is the memory clock band a strap covers, from Min to the clock of
+// the strap. The VBIOS and amdgpu use the first strap of the module in table
+// order whose clock is at or above the memory clock, so a strap covers the
+// clocks above the straps before it. Hidden straps come after a strap with the
+// same or a higher clock, they are never used and cover nothing. Extended is
+// the clock of the strap before memclock raise moved it, or 0.
+type StrapBand struct {
+	Module   uint32
+	Min      uint32
+	Max      uint32
+	Extended uint32
+	Hidden   bool
+}
+
+// raiseStraps extends the highest strap of every module up to clock in Mhz so
+// every clock up to it has a strap. New straps would grow VRAMInfo, so the
+// highest strap is extended rather than cloned. The strap clock is 24 bits in
+// 10 kHz.
+func raiseStraps(bios *Bios, clock uint32) ([]StrapChange, error) {
+	if clock >= AtomMemClockRangeMask/100 {
+		return nil, fmt.Errorf("%d Mhz does not fit the strap clock range, the highest strap clock is %d Mhz", clock, AtomMemClockRangeMask/100-1)
+	}
+	highest := map[uint32]int{}
+	for i, entry := range bios.AtomVRAMTimingEntry {
+		module := strapModule(entry)
+		if h, found := highest[module]; !found || strapClock(entry) > strapClock(bios.AtomVRAMTimingEntry[h]) {
+			highest[module] = i
+		}
+	}
+	changes := []StrapChange{}
+	for module, i := range highest {
+		entry := &bios.AtomVRAMTimingEntry[i]
+		if entry.ClkRange&AtomMemClockRangeMask == AtomMemClockRangeMask || strapClock(*entry) >= clock {
+			continue
+		}
+		entry.ClkRange = module<<24 | clock*100
+		changes = append(changes, StrapChange{module, clock, true})
+	}
+	return changes, nil
+}
+
+// strapBands returns the clock band of every strap, by module and in table
+// order.
+func strapBands(bios Bios, changes []StrapChange, previous map[uint32]uint32) []StrapBand {
+	modules := []uint32{}
+	for _, strap := range bios.AtomVRAMTimingEntry {
+		if module := strapModule(strap); !containsModule(modules, module) {
+			modules = append(modules, module)
+		}
+	}
+	sort.Slice(modules, func(a, b int) bool { return modules[a] < modules[b] })
+
+	bands := []StrapBand{}
+	for _, module := range modules {
+		// covered is the highest clock of the straps before, plus one.
+		covered := uint32(0)
+		for _, strap := range moduleStraps(bios, module) {
+			band := StrapBand{Module: module, Min: covered, Max: strapClock(strap)}
+			if band.Max < covered {
+				band.Min, band.Hidden = 0, true
+			} else {
+				covered = band.Max + 1
+			}
+			for _, change := range changes {
+				if change.Module == band.Module && change.Clock == band.Max {
+					band.Extended = previous[band.Module]
+				}
+			}
+			bands = append(bands, band)
+		}
+	}
+	return bands
+}
+
+func containsModule(modules []uint32, module uint32) bool {
+	for _, m := range modules {
+		if m == module {
+			return true
+		}
+	}
+	return false
+}
+
+// raiseMemoryClock raises the memory overdrive limit and the highest memory
+// clock level to max in Mhz, and extends the highest strap of every module to
+// cover it. A limit at max already only has its straps extended.
+func raiseMemoryClock(filename string, out string, max uint32, force bool) {
+	buffer, bios := openStraps(filename)
+	if bios.Family == FamilyVega10 {
+		fmt.Println(chalk.Red, "memclock raise supports the v7 PowerPlay table, Vega 10 is not supported.", chalk.Reset)
+		os.Exit(1)
+	}
+	mclk := bios.AtomMClkTable.Entries
+	if _, found := bios.Tables["MclkDependency"]; !found || len(mclk) == 0 {
+		fmt.Println(chalk.Red, filename, "has no memory clock levels that could be decoded.", chalk.Reset)
+		os.Exit(1)
+	}
+	// The straps are raised first, they refuse clocks that do not fit.
+	previous := map[uint32]uint32{}
+	for _, entry := range bios.AtomVRAMTimingEntry {
+		if module := strapModule(entry); strapClock(entry) > previous[module] {
+			previous[module] = strapClock(entry)
+		}
+	}
+	changes, err := raiseStraps(&bios, max)
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	if max*100 < bios.AtomPowerplayTable.MaxODMemoryClock {
+		fmt.Println(chalk.Red, fmt.Sprintf("The overdrive limit is %d Mhz, memclock raise does not lower it.", bios.AtomPowerplayTable.MaxODMemoryClock/100), chalk.Reset)
+		os.Exit(1)
+	}
+	if len(bios.AtomHardLimitTable.Entries) > 0 {
+		if limit := bios.AtomHardLimitTable.Entries[0].MclkLimit / 100; limit != 0 && max > limit {
+			fmt.Println(chalk.Red, fmt.Sprintf("%d Mhz exceeds the memory clock hard limit of %d Mhz.", max, limit), chalk.Reset)
+			os.Exit(1)
+		}
+	}
+
+	previousLimit := bios.AtomPowerplayTable.MaxODMemoryClock / 100
+	previousLevel := mclk[len(mclk)-1].Mclk / 100
+	bios.AtomPowerplayTable.MaxODMemoryClock = max * 100
+	if previousLevel < max {
+		mclk[len(mclk)-1].Mclk = max * 100
+	}
+
+	if err := validateDPM(bios); err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	checkStraps(bios, changes, force)
+	if err := saveTables(out, buffer, &bios, []string{"PowerPlayInfo", "MclkDependency", "MemClkPatch"}); err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s%s%s%d -> %d Mhz%s\n", chalk.Bold, "Max memory freq: ", chalk.White, previousLimit, max, chalk.Reset)
+	fmt.Printf("%s%s %d: %s%d -> %d Mhz%s\n", chalk.Bold, "Memory level", len(mclk)-1, chalk.White, previousLevel,
+		mclk[len(mclk)-1].Mclk/100, chalk.Reset)
+	displayStrapBands(strapBands(bios, changes, previous), bios.AtomVRAMEntry)
+}
+
+// displayStrapBands shows the clock band of every strap, by module.
+func displayStrapBands(bands []StrapBand, modules []AtomVRAMEntry) {
+	module := -1
+	for _, band := range bands {
+		if int(band.Module) != module {
+			module = int(band.Module)
+			fmt.Printf("\n%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+			fmt.Printf("%s%s%s\n", chalk.Blue, formatModule(band.Module, modules), chalk.Reset)
+			fmt.Printf("%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+		}
+		if band.Hidden {
+			fmt.Printf("%s%d %s: %s%s%s\n", chalk.Bold, band.Max, "Mhz", chalk.Yellow,
+				"strap never used, a strap before it has the same or a higher clock", chalk.Reset)
+			continue
+		}
+		strap := fmt.Sprintf("strap up to %d Mhz", band.Max)
+		if band.Extended != 0 {
+			strap += fmt.Sprintf(", extended from %d Mhz", band.Extended)
+		}
+		fmt.Printf("%s%d-%d %s: %s%s%s\n", chalk.Bold, band.Min, band.Max, "Mhz", chalk.White, strap, chalk.Reset)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStrapBands(t *testing.T) {
+	strap := func(module uint32, clock uint32) AtomVRAMTimingEntry {
+		return AtomVRAMTimingEntry{ClkRange: module<<24 | clock*100}
+	}
+	bios := Bios{AtomVRAMTimingEntry: []AtomVRAMTimingEntry{
+		strap(1, 800),
+		strap(0, 1000),
+		strap(0, 2000),
+		strap(0, 1500),
+		strap(0, 2000),
+		strap(1, 2250),
+	}}
+	changes := []StrapChange{{1, 2250, true}}
+
+	// The 1500 Mhz strap comes after the 2000 Mhz one, which serves its
+	// clocks, the second 2000 Mhz strap has the clock of the first.
+	expected := []StrapBand{
+		{Module: 0, Min: 0, Max: 1000},
+		{Module: 0, Min: 1001, Max: 2000},
+		{Module: 0, Max: 1500, Hidden: true},
+		{Module: 0, Max: 2000, Hidden: true},
+		{Module: 1, Min: 0, Max: 800},
+		{Module: 1, Min: 801, Max: 2250, Extended: 2000},
+	}
+	bands := strapBands(bios, changes, map[uint32]uint32{1: 2000})
+	if !reflect.DeepEqual(bands, expected) {
+		t.Errorf("bands are %+v, expected %+v", bands, expected)
+	}
+}
+
+func TestRaiseStraps(t *testing.T) {
+	bios := Bios{AtomVRAMTimingEntry: []AtomVRAMTimingEntry{
+		{ClkRange: 0<<24 | 200000},
+		{ClkRange: 0<<24 | 100000},
+		{ClkRange: 1<<24 | 250000},
+	}}
+	changes, err := raiseStraps(&bios, 2250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changes, []StrapChange{{0, 2250, true}}) {
+		t.Errorf("changes are %v, expected the 2000 Mhz strap of module 0 only", changes)
+	}
+	if bios.AtomVRAMTimingEntry[0].ClkRange != 225000 {
+		t.Errorf("strap clock range is 0x%x, expected 0x%x", bios.AtomVRAMTimingEntry[0].ClkRange, 225000)
+	}
+	if _, err := raiseStraps(&bios, AtomMemClockRangeMask/100); err == nil {
+		t.Error("a clock past the strap clock range was accepted")
+	}
+}
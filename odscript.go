@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ttacon/chalk"
+)
+
+// Overdrive scripts apply the clock levels of a bios file at runtime through
+// the amdgpu pp_od_clk_voltage file instead of flashing. Each level is set with
+// "s N clock mV" for the GPU and "m N clock mV" for the memory, "c" commits
+// them. The power cap is set through hwmon in microwatts.
+
+func odScript(filename string, card string, editsFile string, sets []string) {
+	buffer := readFile(filename)
+	bios := openTables(buffer)
+
+	edits, err := collectEdits(editsFile, sets)
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	changes, err := applyEdits(&bios, edits)
+	if err == nil {
+		err = validateEdits(bios, changes)
+	}
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+
+	commands, err := odCommands(bios)
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+
+	fmt.Println("#!/bin/sh")
+	fmt.Printf("# Overdrive settings of %s\n", filepath.Base(filename))
+	for _, change := range changes {
+		fmt.Printf("# %s\n", change)
+	}
+	fmt.Printf("device=%s\n", filepath.Join("/sys", sysfsDRMPath, filepath.Base(card), "device"))
+	fmt.Println("hwmon=$(echo $device/hwmon/hwmon*)")
+	for _, command := range commands {
+		fmt.Printf("echo \"%s\" > $device/pp_od_clk_voltage\n", command)
+	}
+	fmt.Printf("echo %d > $hwmon/power1_cap\n", powerLimit(bios)*1000000)
+	// Automatic fan control follows the fan table of the driver.
+	fmt.Println("echo 2 > $hwmon/pwm1_enable")
+	if containsString(changedTables(changes), "FanTable") {
+		fmt.Println("# The fan table can not be changed at runtime, use a pp_table for the fan edits.")
+		fmt.Println("# amdgpu keeps fan1_max and pwm1_max read-only, fan1_target and pwm1 only set")
+		fmt.Println("# a fixed speed in manual mode. To hold the fan at the maximum of the edited")
+		fmt.Println("# table instead of following a curve:")
+		rpm := uint32(bios.AtomFanTable.FanRPMMax)
+		if bios.Family == FamilyVega10 {
+			rpm = uint32(bios.AtomVega10FanTable.FanAcousticLimitRpm)
+		}
+		fmt.Println("# echo 1 > $hwmon/fan1_enable")
+		fmt.Printf("# echo %d > $hwmon/fan1_target\n", rpm)
+		if bios.Family != FamilyVega10 {
+			fmt.Println("# or, on kernels without fan1_target:")
+			fmt.Println("# echo 1 > $hwmon/pwm1_enable")
+			// PWMHigh is in 0.01 %, pwm1 goes from 0 to 255.
+			fmt.Printf("# echo %d > $hwmon/pwm1\n", uint32(bios.AtomFanTable.PWMHigh)*255/10000)
+		}
+	}
+}
+
+// odCommands returns the pp_od_clk_voltage commands for the clock levels. It
+// refuses clocks above the overdrive limits and levels without a fixed
+// voltage.
+func odCommands(bios Bios) ([]string, error) {
+	commands := []string{}
+	limits := []struct {
+		command string
+		name    string
+		levels  []DPMLevel
+		max     uint32
+	}{
+		{"s", "GPU", sclkLevels(bios), bios.AtomPowerplayTable.MaxODEngineClock / 100},
+		{"m", "memory", mclkLevels(bios), bios.AtomPowerplayTable.MaxODMemoryClock / 100},
+	}
+	for _, limit := range limits {
+		for i, level := range limit.levels {
+			if level.Clock > limit.max {
+				return nil, fmt.Errorf("%s level %d of %d Mhz exceeds the overdrive limit of %d Mhz", limit.name, i, level.Clock, limit.max)
+			}
+			if level.Voltage == 0 {
+				if isVirtualVoltage(level.VoltageID) {
+					return nil, fmt.Errorf("%s level %d uses the virtual voltage 0x%x, give it a fixed voltage first", limit.name, i, level.VoltageID)
+				}
+				return nil, fmt.Errorf("%s level %d has no voltage", limit.name, i)
+			}
+			commands = append(commands, fmt.Sprintf("%s %d %d %d", limit.command, i, level.Clock, level.Voltage))
+		}
+	}
+	return append(commands, "c"), nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PartNumber is what a memory part number tells about a chip. Density is in
+// Mbit per chip, Width in bits. Fields the part number does not encode are
+// left empty.
+type PartNumber struct {
+	Vendor   string
+	Type     string
+	Density  int
+	Width    int
+	Speed    string
+	Voltage  string
+	Revision string
+}
+
+var samsungDensity = map[string]int{
+	"10": 1024,
+	"20": 2048,
+	"41": 4096,
+	"80": 8192,
+}
+
+// Samsung and Hynix speed grades as data rates, Micron and Elpida give the
+// data rate in the grade itself.
+var samsungSpeed = map[string]string{
+	"25": "8 Gbps",
+	"28": "7 Gbps",
+	"03": "6 Gbps",
+	"04": "5 Gbps",
+	"05": "4 Gbps",
+}
+
+var hynixSpeed = map[string]string{
+	"R0": "6 Gbps",
+	"T2": "5 Gbps",
+}
+
+var hynixVoltage = map[byte]string{
+	'Q': "1.5 V",
+	'C': "1.35 V",
+}
+
+// decodePartNumber decodes the GDDR5 part numbers of Samsung (K4G), Hynix
+// (H5GQ, H5GC), Micron (MT51J) and Elpida (EDW, made by Micron since), and the
+// GDDR5X parts of Micron (MT58K).
+func decodePartNumber(partNumber string) (PartNumber, bool) {
+	pn := strings.ToUpper(strings.TrimRight(partNumber, "\x00 "))
+	switch {
+	case strings.HasPrefix(pn, "K4G"):
+		return decodeSamsungPart(pn)
+	case strings.HasPrefix(pn, "H5G"):
+		return decodeHynixPart(pn)
+	case strings.HasPrefix(pn, "MT51J"):
+		return decodeMicronPart(pn, "GDDR5")
+	case strings.HasPrefix(pn, "MT58K"):
+		return decodeMicronPart(pn, "GDDR5X")
+	case strings.HasPrefix(pn, "EDW"):
+		return decodeElpidaPart(pn)
+	}
+	return PartNumber{}, false
+}
+
+// K4G 41 32 5 F E - HC 25: density, width, banks, revision, package, speed.
+func decodeSamsungPart(pn string) (PartNumber, bool) {
+	if len(pn) < 10 {
+		return PartNumber{}, false
+	}
+	density, found := samsungDensity[pn[3:5]]
+	width, err := strconv.Atoi(pn[5:7])
+	if !found || err != nil {
+		return PartNumber{}, false
+	}
+	part := PartNumber{Vendor: "Samsung", Type: "GDDR5", Density: density, Width: width, Revision: pn[8:9]}
+	if dash := strings.Index(pn, "-"); dash >= 0 && len(pn) >= dash+5 {
+		part.Speed = gradeSpeed(pn[dash+3:dash+5], samsungSpeed)
+	}
+	return part, true
+}
+
+// H5G C 4H 2 4 A J R - R0 C: voltage, density, width, banks, revision,
+// package, speed.
+func decodeHynixPart(pn string) (PartNumber, bool) {
+	if len(pn) < 9 || pn[5] != 'H' || pn[6] != '2' {
+		return PartNumber{}, false
+	}
+	density, err := strconv.Atoi(pn[4:5])
+	if err != nil {
+		return PartNumber{}, false
+	}
+	part := PartNumber{
+		Vendor:   "Hynix",
+		Type:     "GDDR5",
+		Density:  density * 1024,
+		Width:    32,
+		Voltage:  hynixVoltage[pn[3]],
+		Revision: pn[8:9],
+	}
+	if dash := strings.Index(pn, "-"); dash >= 0 && len(pn) >= dash+3 {
+		part.Speed = gradeSpeed(pn[dash+1:dash+3], hynixSpeed)
+	}
+	return part, true
+}
+
+// MT51J 256M32 HF -70 :B: depth and width, package, speed in 100 Mbps,
+// revision.
+func decodeMicronPart(pn string, memoryType string) (PartNumber, bool) {
+	organisation := pn[5:]
+	m := strings.Index(organisation, "M")
+	if m <= 0 {
+		return PartNumber{}, false
+	}
+	depth, err := strconv.Atoi(organisation[:m])
+	if err != nil {
+		return PartNumber{}, false
+	}
+	widthEnd := m + 1
+	for widthEnd < len(organisation) && organisation[widthEnd] >= '0' && organisation[widthEnd] <= '9' {
+		widthEnd++
+	}
+	width, err := strconv.Atoi(organisation[m+1 : widthEnd])
+	if err != nil {
+		return PartNumber{}, false
+	}
+	part := PartNumber{Vendor: "Micron", Type: memoryType, Density: depth * width, Width: width}
+	if dash := strings.Index(pn, "-"); dash >= 0 {
+		grade := pn[dash+1:]
+		if colon := strings.Index(grade, ":"); colon >= 0 {
+			grade = grade[:colon]
+		}
+		if rate, err := strconv.Atoi(grade); err == nil {
+			part.Speed = fmt.Sprintf("%g Gbps (%s)", float64(rate)/10, grade)
+		} else {
+			part.Speed = grade
+		}
+	}
+	if colon := strings.Index(pn, ":"); colon >= 0 && colon+1 < len(pn) {
+		part.Revision = pn[colon+1 : colon+2]
+	}
+	return part, true
+}
+
+// EDW 40 32 B ABG -70 -F: density, width, revision, package, speed.
+func decodeElpidaPart(pn string) (PartNumber, bool) {
+	if len(pn) < 8 {
+		return PartNumber{}, false
+	}
+	density, err := strconv.Atoi(pn[3:5])
+	if err != nil || density%10 != 0 {
+		return PartNumber{}, false
+	}
+	width, err := strconv.Atoi(pn[5:7])
+	if err != nil {
+		return PartNumber{}, false
+	}
+	part := PartNumber{Vendor: "Elpida", Type: "GDDR5", Density: density / 10 * 1024, Width: width, Revision: pn[7:8]}
+	if dash := strings.Index(pn, "-"); dash >= 0 && len(pn) >= dash+3 {
+		part.Speed = gradeSpeed(pn[dash+1:dash+3], nil)
+	}
+	return part, true
+}
+
+// gradeSpeed returns the data rate of a speed grade. Without a table the
+// first digit of the grade is the data rate in Gbps.
+func gradeSpeed(grade string, speeds map[string]string) string {
+	if speeds != nil {
+		if speed, found := speeds[grade]; found {
+			return fmt.Sprintf("%s (%s)", speed, grade)
+		}
+		return grade
+	}
+	if grade[0] >= '1' && grade[0] <= '9' {
+		return fmt.Sprintf("%c Gbps (%s)", grade[0], grade)
+	}
+	return grade
+}
+
+// vramDensityMbit returns the chip density of a VRAM entry density code in
+// Mbit. The high nibble is the depth, 4M times a power of two, the low nibble
+// the width, 4 bits times a power of two.
+func vramDensityMbit(density byte) int {
+	return (4 << (density >> 4)) * (4 << (density & 0xf))
+}
+
+// checkPartNumber compares a decoded part number with the density and size of
+// its VRAM entry. A module has a chip per channel, or two in clamshell mode.
+func checkPartNumber(part PartNumber, entry AtomVRAMEntry) []string {
+	problems := []string{}
+	if density := vramDensityMbit(entry.Density); density != part.Density {
+		problems = append(problems, fmt.Sprintf("part number density of %d Mbit does not match the entry density of %d Mbit",
+			part.Density, density))
+	}
+	chipSize := part.Density / 8
+	channels := int(entry.ChannelNum)
+	size := int(entry.MemorySize)
+	if channels > 0 && size != channels*chipSize && size != 2*channels*chipSize {
+		problems = append(problems, fmt.Sprintf("size of %d MB does not match %d or %d chips of %d Mbit",
+			size, channels, 2*channels, part.Density))
+	}
+	return problems
+}
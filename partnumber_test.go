@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodePartNumber(t *testing.T) {
+	tests := []struct {
+		partNumber string
+		expected   PartNumber
+	}{
+		{"K4G41325FC-HC03", PartNumber{Vendor: "Samsung", Type: "GDDR5", Density: 4096, Width: 32, Speed: "6 Gbps (03)", Revision: "F"}},
+		{"K4G80325FB-HC25\x00\x00", PartNumber{Vendor: "Samsung", Type: "GDDR5", Density: 8192, Width: 32, Speed: "8 Gbps (25)", Revision: "F"}},
+		{"H5GC4H24AJR-T2C", PartNumber{Vendor: "Hynix", Type: "GDDR5", Density: 4096, Width: 32, Speed: "5 Gbps (T2)", Voltage: "1.35 V", Revision: "A"}},
+		{"H5GQ8H24MJR-R0C", PartNumber{Vendor: "Hynix", Type: "GDDR5", Density: 8192, Width: 32, Speed: "6 Gbps (R0)", Voltage: "1.5 V", Revision: "M"}},
+		{"MT51J256M32HF-80", PartNumber{Vendor: "Micron", Type: "GDDR5", Density: 8192, Width: 32, Speed: "8 Gbps (80)"}},
+		{"MT51J256M32HF-70:B", PartNumber{Vendor: "Micron", Type: "GDDR5", Density: 8192, Width: 32, Speed: "7 Gbps (70)", Revision: "B"}},
+		{"MT58K256M32JA-100", PartNumber{Vendor: "Micron", Type: "GDDR5X", Density: 8192, Width: 32, Speed: "10 Gbps (100)"}},
+		{"EDW4032BABG-70-F", PartNumber{Vendor: "Elpida", Type: "GDDR5", Density: 4096, Width: 32, Speed: "7 Gbps (70)", Revision: "B"}},
+	}
+	for _, test := range tests {
+		part, ok := decodePartNumber(test.partNumber)
+		if !ok {
+			t.Errorf("%q was not decoded", test.partNumber)
+			continue
+		}
+		if !reflect.DeepEqual(part, test.expected) {
+			t.Errorf("%q decoded as %+v, expected %+v", test.partNumber, part, test.expected)
+		}
+	}
+
+	for _, partNumber := range []string{"", "K4G9932", "K4G99325FC-HC03", "H5GQ8X24MJR-R0C", "MT51JM32", "W1234"} {
+		if part, ok := decodePartNumber(partNumber); ok {
+			t.Errorf("%q decoded as %+v", partNumber, part)
+		}
+	}
+}
+
+func TestCheckPartNumber(t *testing.T) {
+	part, _ := decodePartNumber("K4G41325FC-HC03")
+	tests := []struct {
+		name     string
+		entry    AtomVRAMEntry
+		problems int
+	}{
+		// 0x53 is 128M deep and 32 bits wide, 4 Gbit.
+		{"matching", AtomVRAMEntry{Density: 0x53, ChannelNum: 8, MemorySize: 4096}, 0},
+		{"clamshell", AtomVRAMEntry{Density: 0x53, ChannelNum: 8, MemorySize: 8192}, 0},
+		{"density", AtomVRAMEntry{Density: 0x63, ChannelNum: 8, MemorySize: 4096}, 1},
+		{"size", AtomVRAMEntry{Density: 0x53, ChannelNum: 8, MemorySize: 3072}, 1},
+		{"density and size", AtomVRAMEntry{Density: 0x63, ChannelNum: 8, MemorySize: 3072}, 2},
+	}
+	for _, test := range tests {
+		if problems := checkPartNumber(part, test.entry); len(problems) != test.problems {
+			t.Errorf("%s: %d problems %v, expected %d", test.name, len(problems), problems, test.problems)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+)
+
+// The powertune limits are stored in W, A and C as they are shown.
+var powertuneFields = []fieldFlag{
+	{"tdp", "TDP", 1, "TDP in W."},
+	{"configurable-tdp", "ConfigurableTDP", 1, "Configurable TDP in W."},
+	{"tdc", "TDC", 1, "TDC in A."},
+	{"max-power-limit", "MaximumPowerDeliveryLimit", 1, "Maximum power delivery limit in W."},
+	{"edc-limit", "EDCLimit", 1, "EDC limit in A."},
+	{"tjmax", "TjMax", 1, "Maximum temperature in C."},
+	{"shutdown-temp", "SoftwareShutdownTemp", 1, "Software shutdown temperature in C."},
+	{"hotspot-temp", "TemperatureLimitHotspot", 1, "Hotspot temperature limit in C."},
+	{"liquid1-temp", "TemperatureLimitLiquid1", 1, "Liquid 1 temperature limit in C."},
+	{"liquid2-temp", "TemperatureLimitLiquid2", 1, "Liquid 2 temperature limit in C."},
+	{"vr-vddc-temp", "TemperatureLimitVrVddc", 1, "VDDC regulator temperature limit in C."},
+	{"vr-mvdd-temp", "TemperatureLimitVrMvdd", 1, "MVDD regulator temperature limit in C."},
+	{"plx-temp", "TemperatureLimitPlx", 1, "PLX temperature limit in C."},
+}
+
+var powerplayFields = []fieldFlag{
+	{"power-control-limit", "PowerControlLimit", 1, "Power control limit in %."},
+}
+
+// validatePowertune refuses the Vega 10 powertune table, the flags of
+// powertune set name the fields of the v7 table.
+func validatePowertune(bios Bios) error {
+	if bios.Family == FamilyVega10 {
+		return fmt.Errorf("powertune set supports the v7 powertune table, use pptable apply-edits for Vega 10")
+	}
+	return validatePowertuneLimits(bios)
+}
+
+// validatePowertuneLimits checks that the power targets stay under the maximum
+// power delivery limit and TjMax under the shutdown temperature. The Vega 10
+// table has no such limits.
+func validatePowertuneLimits(bios Bios) error {
+	if bios.Family == FamilyVega10 {
+		return nil
+	}
+	powertune := bios.AtomPowertuneTable
+	if powertune.TDP > powertune.MaximumPowerDeliveryLimit {
+		return fmt.Errorf("TDP of %d W exceeds the maximum power delivery limit of %d W",
+			powertune.TDP, powertune.MaximumPowerDeliveryLimit)
+	}
+	if powertune.ConfigurableTDP > powertune.MaximumPowerDeliveryLimit {
+		return fmt.Errorf("configurable TDP of %d W exceeds the maximum power delivery limit of %d W",
+			powertune.ConfigurableTDP, powertune.MaximumPowerDeliveryLimit)
+	}
+	if powertune.SoftwareShutdownTemp != 0 && powertune.TjMax >= powertune.SoftwareShutdownTemp {
+		return fmt.Errorf("TjMax of %d C must stay under the shutdown temperature of %d C",
+			powertune.TjMax, powertune.SoftwareShutdownTemp)
+	}
+	return nil
+}
+
+// validatePowerplay refuses a power control limit above 100 %, the range by
+// which the power limit can be raised.
+func validatePowerplay(bios Bios) error {
+	limit := bios.AtomPowerplayTable.PowerControlLimit
+	if bios.Family == FamilyVega10 {
+		limit = bios.AtomVega10PowerplayTable.PowerControlLimit
+	}
+	if limit > 100 {
+		return fmt.Errorf("power control limit of %d %% is above 100 %%", limit)
+	}
+	return nil
+}
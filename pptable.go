@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ttacon/chalk"
+)
+
+// The Linux amdgpu driver accepts a soft PowerPlay table written to
+// /sys/class/drm/cardN/device/pp_table. The file is the PowerPlayInfo table of
+// the ROM as is, from its common header up to StructureSize.
+
+// isPowerplayBlob tells a bare PowerPlay table from a ROM image. A ROM starts
+// with the 0x55aa option ROM signature, a PowerPlay table with its common
+// header, whose format revision is repeated in TableRevision.
+func isPowerplayBlob(buffer []byte) bool {
+	if len(buffer) < 5 || (buffer[0] == 0x55 && buffer[1] == 0xaa) {
+		return false
+	}
+	size := int(binary.LittleEndian.Uint16(buffer))
+	format := buffer[2]
+	return size > 5 && size <= len(buffer) && (format == 7 || format == 8) && buffer[4] == format
+}
+
+// openTables decodes a ROM image or a bare PowerPlay table.
+func openTables(buffer []byte) Bios {
+	if isPowerplayBlob(buffer) {
+		return unpackPowerplay(buffer)
+	}
+	return unpackData(buffer)
+}
+
+// powerplayBlob returns the PowerPlayInfo table of a ROM image.
+func powerplayBlob(buffer []byte, bios Bios) ([]byte, error) {
+	location, found := bios.Tables["PowerPlayInfo"]
+	if !found {
+		return nil, fmt.Errorf("no PowerPlay table was decoded")
+	}
+	size := int(uint16(bios.AtomPowerplayTable.Header.StructureSize))
+	if size == 0 || location.Offset+size > len(buffer) {
+		return nil, fmt.Errorf("PowerPlay table size %d at 0x%x is out of range", size, location.Offset)
+	}
+	return buffer[location.Offset : location.Offset+size], nil
+}
+
+func extractPowerplay(filename string, out string) {
+	buffer := readFile(filename)
+	bios := unpackData(buffer)
+
+	blob, err := powerplayBlob(buffer, bios)
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	writeFile(out, blob)
+	fmt.Printf("%s%s%s%d bytes, revision %s (%s)%s\n", chalk.Bold, "PowerPlay table: ", chalk.White,
+		len(blob), bios.Tables["PowerPlayInfo"].Revision, bios.Tables["PowerPlayInfo"].Layout, chalk.Reset)
+}
+
+func applyPowerplayEdits(filename string, out string, editsFile string, sets []string) {
+	buffer := readFile(filename)
+	bios := unpackPowerplay(buffer)
+	if _, found := bios.Tables["PowerPlayInfo"]; !found {
+		fmt.Println(chalk.Red, filename, "is not a PowerPlay table.", chalk.Reset)
+		os.Exit(1)
+	}
+
+	edits, err := collectEdits(editsFile, sets)
+	if err == nil && len(edits) == 0 {
+		err = fmt.Errorf("no edits given, use --edits or --set")
+	}
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+
+	changes, err := applyEdits(&bios, edits)
+	if err == nil {
+		err = validateEdits(bios, changes)
+	}
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	if err := saveTables(out, buffer, &bios, changedTables(changes)); err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	displayChanges(changes)
+}
+
+func writeFile(filename string, buffer []byte) {
+	if err := ioutil.WriteFile(filename, buffer, 0644); err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,36 @@
+// Code generated by presetgen.go from presets/*.preset. DO NOT EDIT.
+
+package main
+
+// builtinPresets are the preset files built into the tool.
+var builtinPresets = []struct {
+	File string
+	Data string
+}{
+	{"presets/hynix-h5gc4-1500.preset", `# The 1500 Mhz strap of the Hynix H5GC4H24AJR chips of RX 470 and RX 480
+# cards, used on their straps from 1625 Mhz up.
+name: hynix-h5gc4-1500
+version: 1
+part: H5GC4H24AJR
+clock: 1625-2000
+description: Hynix 4 Gbit, 1500 Mhz strap
+strap: 999000000000000022559D0010DE5B4480551312B74C450A00400600750414206A8900A00200312010112D34A42A3816
+`},
+	{"presets/hynix-h5gq4-1500.preset", `# The same strap for the 1.5 V Hynix H5GQ4H24AJR chips.
+name: hynix-h5gq4-1500
+version: 1
+part: H5GQ4H24AJR
+clock: 1625-2000
+description: Hynix 4 Gbit 1.5 V, 1500 Mhz strap
+strap: 999000000000000022559D0010DE5B4480551312B74C450A00400600750414206A8900A00200312010112D34A42A3816
+`},
+	{"presets/samsung-k4g4-1750.preset", `# The 1750 Mhz strap of the Samsung K4G4 chips of RX 470 and RX 480 cards,
+# widely used on all their straps from 1750 Mhz up.
+name: samsung-k4g4-1750
+version: 1
+part: K4G41325F
+clock: 1750-2000
+description: Samsung 4 Gbit, 1750 Mhz strap
+strap: 777000000000000022CC1C00AD615C41C0590E152ECC8608006007000B031420FA8900A00300000010122F3FBA354019
+`},
+}
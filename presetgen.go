@@ -0,0 +1,61 @@
+//go:build ignore
+// +build ignore
+
+// presetgen writes presetcatalog.go, the built-in strap presets, from the
+// preset files in the presets directory. Run it with go generate after a
+// preset is added or changed.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func main() {
+	files, err := filepath.Glob(filepath.Join("presets", "*.preset"))
+	if err != nil {
+		fail(err)
+	}
+	sort.Strings(files)
+
+	source := &bytes.Buffer{}
+	fmt.Fprintln(source, "// Code generated by presetgen.go from presets/*.preset. DO NOT EDIT.")
+	fmt.Fprintln(source)
+	fmt.Fprintln(source, "package main")
+	fmt.Fprintln(source)
+	fmt.Fprintln(source, "// builtinPresets are the preset files built into the tool.")
+	fmt.Fprintln(source, "var builtinPresets = []struct {")
+	fmt.Fprintln(source, "\tFile string")
+	fmt.Fprintln(source, "\tData string")
+	fmt.Fprintln(source, "}{")
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			fail(err)
+		}
+		if strings.Contains(string(data), "`") {
+			fail(fmt.Errorf("%s: presets can not hold backquotes", file))
+		}
+		fmt.Fprintf(source, "\t{%q, `%s`},\n", filepath.ToSlash(file), data)
+	}
+	fmt.Fprintln(source, "}")
+
+	formatted, err := format.Source(source.Bytes())
+	if err != nil {
+		fail(err)
+	}
+	if err := ioutil.WriteFile("presetcatalog.go", formatted, 0644); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
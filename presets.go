@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ttacon/chalk"
+)
+
+// presetCatalogVersion is the version of the built-in preset catalog. Bump it
+// when presets are added or changed.
+const presetCatalogVersion = 1
+
+// The built-in presets are the files in the presets directory, compiled into
+// presetcatalog.go by presetgen.go.
+//go:generate go run presetgen.go
+
+// StrapPreset is a timing strap for the modules with a part number, applied to
+// their straps with a clock in the range. Source is "built-in" or the file the
+// preset was loaded from.
+type StrapPreset struct {
+	Name        string
+	Version     int
+	Part        string
+	Clock       ClockRange
+	Description string
+	Strap       [0x30]byte
+	Source      string
+}
+
+// parsePreset parses a preset file, key: value lines with # comments.
+func parsePreset(filename string, data []byte) (StrapPreset, error) {
+	preset := StrapPreset{Source: filename}
+	found := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for number := 1; scanner.Scan(); number++ {
+		line := scanner.Text()
+		if comment := strings.Index(line, "#"); comment >= 0 {
+			line = line[:comment]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		pair := strings.SplitN(line, ":", 2)
+		if len(pair) != 2 {
+			return preset, fmt.Errorf("%s:%d: expected key: value", filename, number)
+		}
+		key := strings.TrimSpace(pair[0])
+		value := strings.TrimSpace(pair[1])
+		var err error
+		switch key {
+		case "name":
+			preset.Name = value
+		case "version":
+			preset.Version, err = strconv.Atoi(value)
+		case "part":
+			preset.Part = strings.ToUpper(value)
+		case "clock":
+			var ranges []ClockRange
+			ranges, err = parseClockRanges([]string{value})
+			if err == nil && len(ranges) != 1 {
+				err = fmt.Errorf("one clock range expected")
+			}
+			if err == nil {
+				preset.Clock = ranges[0]
+			}
+		case "description":
+			preset.Description = value
+		case "strap":
+			var strap []byte
+			strap, err = hex.DecodeString(value)
+			if err == nil && len(strap) != len(preset.Strap) {
+				err = fmt.Errorf("strap of %d bytes, expected %d", len(strap), len(preset.Strap))
+			}
+			copy(preset.Strap[:], strap)
+		default:
+			err = fmt.Errorf("unknown key %s", key)
+		}
+		if err != nil {
+			return preset, fmt.Errorf("%s:%d: %s", filename, number, err)
+		}
+		found[key] = true
+	}
+	for _, key := range []string{"name", "part", "clock", "strap"} {
+		if !found[key] {
+			return preset, fmt.Errorf("%s: %s is missing", filename, key)
+		}
+	}
+	return preset, nil
+}
+
+// loadPresets returns the built-in presets and the presets in dir, sorted by
+// name. A preset in dir replaces the built-in preset of the same name.
+func loadPresets(dir string) ([]StrapPreset, error) {
+	presets := map[string]StrapPreset{}
+	for _, builtin := range builtinPresets {
+		preset, err := parsePreset(builtin.File, []byte(builtin.Data))
+		if err != nil {
+			return nil, err
+		}
+		preset.Source = "built-in"
+		presets[preset.Name] = preset
+	}
+
+	if dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.preset"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			preset, err := parsePreset(file, data)
+			if err != nil {
+				return nil, err
+			}
+			presets[preset.Name] = preset
+		}
+	}
+
+	list := make([]StrapPreset, 0, len(presets))
+	for _, preset := range presets {
+		list = append(list, preset)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].Name < list[b].Name })
+	return list, nil
+}
+
+// matchesPart reports whether a module part number is one of the preset.
+func (p StrapPreset) matchesPart(partNumber string) bool {
+	return strings.HasPrefix(strings.ToUpper(strings.TrimRight(partNumber, "\x00 ")), p.Part)
+}
+
+// applyPreset writes the strap of a preset to the straps in its clock range
+// of the modules with its part number, or of one module if module is not -1.
+// Presets hold Polaris straps, they are only written to straps of the same
+// registers.
+func applyPreset(bios *Bios, preset StrapPreset, module int) ([]StrapChange, error) {
+	if err := checkStrapLayout(*bios); err != nil {
+		return nil, fmt.Errorf("presets hold Polaris straps, %s", err)
+	}
+	if module >= len(bios.AtomVRAMEntry) {
+		return nil, fmt.Errorf("invalid module %d, the bios has %d modules", module, len(bios.AtomVRAMEntry))
+	}
+	modules := map[uint32]bool{}
+	for i, entry := range bios.AtomVRAMEntry {
+		if (module < 0 || i == module) && preset.matchesPart(entry.MemPNString) {
+			modules[uint32(i)] = true
+		}
+	}
+	if len(modules) == 0 {
+		if module >= 0 {
+			return nil, fmt.Errorf("preset %s is for %s, module %d is %s", preset.Name, preset.Part, module,
+				strings.TrimRight(bios.AtomVRAMEntry[module].MemPNString, "\x00 "))
+		}
+		return nil, fmt.Errorf("preset %s is for %s, the bios has no module with that part number", preset.Name, preset.Part)
+	}
+
+	ranges := []ClockRange{preset.Clock}
+	changes := []StrapChange{}
+	for i, entry := range bios.AtomVRAMTimingEntry {
+		if !modules[strapModule(entry)] || !inClockRanges(strapClock(entry), ranges) {
+			continue
+		}
+		changes = append(changes, StrapChange{strapModule(entry), strapClock(entry), entry.Latency != preset.Strap})
+		bios.AtomVRAMTimingEntry[i].Latency = preset.Strap
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("the modules of preset %s have no straps from %d to %d Mhz", preset.Name, preset.Clock.Min, preset.Clock.Max)
+	}
+	return changes, nil
+}
+
+func listPresets(dir string) {
+	presets, err := loadPresets(dir)
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	fmt.Printf("%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	fmt.Printf("%s%s %d%s\n", chalk.Blue, "Strap presets, catalog version", presetCatalogVersion, chalk.Reset)
+	fmt.Printf("%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	for _, preset := range presets {
+		fmt.Printf("%s%s v%d: %s%s, %d-%d Mhz, %s (%s)%s\n", chalk.Bold, preset.Name, preset.Version, chalk.White,
+			preset.Part, preset.Clock.Min, preset.Clock.Max, preset.Description, preset.Source, chalk.Reset)
+	}
+}
+
+func applyPresetFile(filename string, out string, name string, module int, dir string, force bool) {
+	presets, err := loadPresets(dir)
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	var preset *StrapPreset
+	for i := range presets {
+		if presets[i].Name == name {
+			preset = &presets[i]
+		}
+	}
+	if preset == nil {
+		fmt.Println(chalk.Red, "Unknown preset", name+", see timings presets.", chalk.Reset)
+		os.Exit(1)
+	}
+
+	buffer, bios := openStraps(filename)
+	changes, err := applyPreset(&bios, *preset, module)
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	checkStraps(bios, changes, force)
+	if err := saveTables(out, buffer, &bios, []string{"MemClkPatch"}); err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	displayStrapChanges(changes, fmt.Sprintf("preset %s v%d", preset.Name, preset.Version), bios.AtomVRAMEntry)
+}
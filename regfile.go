@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/ttacon/chalk"
+)
+
+// On Windows the driver loads a soft PowerPlay table from the
+// PP_PhmSoftPowerPlayTable REG_BINARY value of the display adapter class key.
+// The .reg files are written the way regedit exports them, hex bytes wrapped
+// at 80 columns with CRLF line endings.
+
+const (
+	RegDisplayClass  = `HKEY_LOCAL_MACHINE\SYSTEM\CurrentControlSet\Control\Class\{4d36e968-e325-11ce-bfc1-08002be10318}`
+	RegSoftPowerPlay = "PP_PhmSoftPowerPlayTable"
+	regLineWidth     = 80
+	regFileSignature = "Windows Registry Editor Version 5.00"
+)
+
+var regClassKeyPattern = regexp.MustCompile(`^[0-9]{4}$`)
+
+// formatRegFile formats a PowerPlay table as a .reg file for the adapter with
+// the given class key, e.g. 0000.
+func formatRegFile(blob []byte, classKey string) string {
+	var out bytes.Buffer
+	out.WriteString(regFileSignature + "\r\n\r\n")
+	out.WriteString("[" + RegDisplayClass + `\` + classKey + "]\r\n")
+
+	line := `"` + RegSoftPowerPlay + `"=hex:`
+	for i, value := range blob {
+		item := fmt.Sprintf("%02x", value)
+		if i < len(blob)-1 {
+			item += ","
+		}
+		// Leave room for the continuation backslash.
+		if len(line)+len(item) > regLineWidth-1 {
+			out.WriteString(line + "\\\r\n")
+			line = "  "
+		}
+		line += item
+	}
+	out.WriteString(line + "\r\n\r\n")
+	return out.String()
+}
+
+// parseRegFile returns the PP_PhmSoftPowerPlayTable value of a .reg file.
+// Files saved by regedit are UTF-16, others plain text.
+func parseRegFile(data []byte) ([]byte, error) {
+	text := string(data)
+	if len(data) >= 2 && data[0] == 0xff && data[1] == 0xfe {
+		units := make([]uint16, 0, len(data)/2)
+		for i := 2; i+1 < len(data); i += 2 {
+			units = append(units, uint16(data[i])|uint16(data[i+1])<<8)
+		}
+		text = string(utf16.Decode(units))
+	}
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	text = strings.Replace(text, "\\\n", "", -1)
+
+	prefix := `"` + strings.ToLower(RegSoftPowerPlay) + `"=hex:`
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(strings.ToLower(line), prefix) {
+			continue
+		}
+		blob := []byte{}
+		for _, item := range strings.Split(line[len(prefix):], ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			value, err := strconv.ParseUint(item, 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid byte %q in %s", item, RegSoftPowerPlay)
+			}
+			blob = append(blob, byte(value))
+		}
+		return blob, nil
+	}
+	return nil, fmt.Errorf("no %s hex value found", RegSoftPowerPlay)
+}
+
+func exportRegFile(filename string, classKey string) {
+	if !regClassKeyPattern.MatchString(classKey) {
+		fmt.Println(chalk.Red, "The class key must be four digits, e.g. 0000.", chalk.Reset)
+		os.Exit(1)
+	}
+	buffer := readFile(filename)
+	bios := openTables(buffer)
+	blob, err := powerplayBlob(buffer, bios)
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	fmt.Print(formatRegFile(blob, classKey))
+}
+
+func importRegFile(filename string, out string) {
+	blob, err := parseRegFile(readFile(filename))
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	if out != "" {
+		writeFile(out, blob)
+	}
+	openPowerplay(blob)
+}
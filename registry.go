@@ -0,0 +1,738 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+
+	"github.com/ttacon/chalk"
+	"gopkg.in/restruct.v1"
+)
+
+// Tables are decoded through a registry of layouts keyed by the table name and
+// its revision. Tables with an AtomCommonTableHeader use the format and content
+// revision of that header. The PowerPlay sub-tables have no common header,
+// they are keyed by the format revision of the PowerPlay table and their own
+// RevID.
+//
+// Support for a new revision is added by registering its layout, the parse
+// flow in unpackData does not need to change.
+
+type TableRevision struct {
+	Format  byte
+	Content byte
+}
+
+func (r TableRevision) String() string {
+	return fmt.Sprintf("%d.%d", r.Format, r.Content)
+}
+
+// TableLocation records where a table was found and which layout decoded it.
+type TableLocation struct {
+	Offset   int
+	Revision TableRevision
+	Layout   string
+}
+
+type tableDecoder func(buffer []byte, offset int, bios *Bios) error
+
+type tableLayout struct {
+	Name   string
+	Decode tableDecoder
+}
+
+var tableLayouts = map[string]map[TableRevision]tableLayout{}
+
+func registerTable(table string, format byte, content byte, name string, decode tableDecoder) {
+	if tableLayouts[table] == nil {
+		tableLayouts[table] = map[TableRevision]tableLayout{}
+	}
+	tableLayouts[table][TableRevision{format, content}] = tableLayout{name, decode}
+}
+
+func init() {
+	registerTable("PowerPlayInfo", 7, 1, "Tonga", decodeTongaPowerplay)
+	for revision := byte(0); revision < 3; revision++ {
+		registerTable("PowerTuneTable", 7, revision, "Tonga", decodeTongaPowertune)
+	}
+	for revision := byte(0); revision < 8; revision++ {
+		registerTable("FanTable", 7, revision, "Tonga", decodeTongaFan)
+	}
+	registerTable("PowerTuneTable", 7, 3, "Fiji", decodeFijiPowertune)
+	registerTable("PowerTuneTable", 7, 4, "Polaris", decodeFijiPowertune)
+	registerTable("FanTable", 7, 8, "Fiji", decodeFijiFan)
+	registerTable("FanTable", 7, 9, "Polaris", decodeFijiFan)
+	registerTable("SclkDependency", 7, 0, "Tonga", decodeTongaSclk)
+	registerTable("SclkDependency", 7, 1, "Polaris", decodePolarisSclk)
+	registerTable("MclkDependency", 7, 0, "Tonga", decodeTongaMclk)
+	registerTable("MclkDependency", 7, 1, "Tonga", decodeTongaMclk)
+	registerTable("VddcLookup", 7, 0, "Tonga", decodeTongaVoltageLookup)
+	registerTable("VddcLookup", 7, 1, "Tonga", decodeTongaVoltageLookup)
+	// pptable_v1_0.h defines the hard limit table as RevID 0, Polaris ROMs
+	// ship it as RevID 52 with the same layout.
+	registerTable("HardLimit", 7, 0, "Tonga", decodeTongaHardLimit)
+	registerTable("HardLimit", 7, 52, "Tonga", decodeTongaHardLimit)
+	registerTable("MMDependency", 7, 0, "Tonga", decodeTongaMMDependency)
+	registerTable("PowerPlayInfo", 8, 1, "Vega 10", decodeVega10Powerplay)
+	registerTable("GfxclkDependency", 8, 0, "Vega 10", decodeVega10GfxClkV1)
+	registerTable("GfxclkDependency", 8, 1, "Vega 10 V2", decodeVega10GfxClk)
+	registerTable("SocclkDependency", 8, 0, "Vega 10", decodeVega10SocClk)
+	registerTable("DcefclkDependency", 8, 0, "Vega 10", decodeVega10DcefClk)
+	registerTable("MclkDependency", 8, 0, "Vega 10", decodeVega10MClk)
+	registerTable("VddcLookup", 8, 0, "Vega 10", decodeVega10VoltageLookup)
+	registerTable("VddcLookup", 8, 1, "Vega 10", decodeVega10VoltageLookup)
+	registerTable("FanTable", 8, 0x0b, "Vega 10 V2", decodeVega10Fan)
+	registerTable("PowerTuneTable", 8, 6, "Vega 10 V2", decodeVega10Powertune)
+	registerTable("VoltageObjectInfo", 3, 1, "V3.1", decodeVoltageObjectInfoV31)
+	registerTable("VRAMInfo", 2, 1, "V2.1", decodeVRAMInfoV21)
+	registerTable("VRAMInfo", 2, 2, "V2.2", decodeVRAMInfoV22)
+	registerTable("MCInitParameter", 1, 1, "V1.1", decodeMCInitParameterV11)
+	registerTable("MCInitParameter", 2, 1, "V2.1", decodeMCInitParameterV21)
+	registerTable("MemoryTrainingInfo", 2, 1, "V2.1", decodeMemoryTrainingInfoV21)
+	registerTable("MemoryTrainingInfo", 3, 1, "V3.1", decodeMemoryTrainingInfoV31)
+	// The VRAMInfo register tables have no header, they are keyed by the
+	// VRAMInfo revision.
+	for _, content := range []byte{1, 2} {
+		registerTable("MemAdjust", 2, content, "Init Reg Block", decodeMemAdjust)
+		registerTable("MemClkPatch", 2, content, "Strap", decodeMemClkPatch)
+	}
+	registerTable("McAdjustPerTile", 2, 2, "Init Reg Block", decodeMcAdjustPerTile)
+	registerTable("McPhyInit", 2, 2, "Init Reg Block", decodeMcPhyInit)
+	registerTable("DramDataRemap", 2, 2, "V2", decodeDramDataRemap)
+}
+
+// decodeTable looks up the layout for the revision and decodes the table at
+// offset into the bios. Unknown revisions are rejected with an error, nothing
+// is decoded for them.
+func decodeTable(table string, revision TableRevision, buffer []byte, offset int, bios *Bios) error {
+	if offset < 0 || offset >= len(buffer) {
+		return fmt.Errorf("%s offset 0x%x is out of range", table, offset)
+	}
+	layout, found := tableLayouts[table][revision]
+	if !found {
+		return fmt.Errorf("unsupported %s revision %s at 0x%x", table, revision, offset)
+	}
+	if err := layout.Decode(buffer, offset, bios); err != nil {
+		return fmt.Errorf("error decoding %s revision %s: %s", table, revision, err)
+	}
+	bios.Tables[table] = TableLocation{offset, revision, layout.Name}
+	return nil
+}
+
+// headerRevision reads the revision of a table with an AtomCommonTableHeader.
+func headerRevision(buffer []byte, offset int) TableRevision {
+	if offset < 0 || offset+4 > len(buffer) {
+		return TableRevision{}
+	}
+	return TableRevision{buffer[offset+2], buffer[offset+3]}
+}
+
+// subTableRevision reads the RevID of a PowerPlay sub-table.
+func subTableRevision(format byte, buffer []byte, offset int) TableRevision {
+	if offset < 0 || offset >= len(buffer) {
+		return TableRevision{format, 0}
+	}
+	return TableRevision{format, buffer[offset]}
+}
+
+// warnTable reports a table that did not decode. show lists it with the
+// diagnostics, other commands warn on stderr so their output, such as that of
+// reg-export and od-script, can be redirected.
+func warnTable(err error) {
+	if collectingDiagnostics {
+		addDiagnostic("Table", "%s", err)
+		return
+	}
+	fmt.Fprintln(os.Stderr, chalk.Yellow, err, chalk.Reset)
+}
+
+// unpackAt is unpack that reports errors instead of exiting.
+func unpackAt(buffer []byte, offset int, object interface{}) error {
+	if offset < 0 || offset >= len(buffer) {
+		return fmt.Errorf("offset 0x%x is out of range", offset)
+	}
+	return restruct.Unpack(buffer[offset:], binary.LittleEndian, object)
+}
+
+func decodeTongaPowerplay(buffer []byte, offset int, bios *Bios) error {
+	table := AtomPowerplayTable{}
+	if err := unpackAt(buffer, offset, &table); err != nil {
+		return err
+	}
+	bios.AtomPowerplayTable = table
+
+	format := table.Header.TableFormatRevision
+	subTables := []subTable{
+		{"PowerTuneTable", table.PowerTuneTableOffset},
+		{"FanTable", table.FanTableOffset},
+		{"MclkDependency", table.MclkDependencyTableOffset},
+		{"SclkDependency", table.SclkDependencyTableOffset},
+		{"VddcLookup", table.VddcLookupTableOffset},
+		{"HardLimit", table.HardLimitTableOffset},
+		{"MMDependency", table.MMDependencyTableOffset},
+	}
+	decodeSubTables(buffer, offset, format, subTables, bios)
+	return nil
+}
+
+type subTable struct {
+	name   string
+	offset uint16
+}
+
+// decodeSubTables decodes the PowerPlay sub-tables at their offsets relative to
+// the PowerPlay table. A sub-table that fails to decode does not stop the rest.
+func decodeSubTables(buffer []byte, offset int, format byte, subTables []subTable, bios *Bios) {
+	for _, subTable := range subTables {
+		if subTable.offset == 0 {
+			continue
+		}
+		subOffset := offset + int(subTable.offset)
+		revision := subTableRevision(format, buffer, subOffset)
+		if err := decodeTable(subTable.name, revision, buffer, subOffset, bios); err != nil {
+			warnTable(err)
+		}
+	}
+}
+
+func decodeTongaPowertune(buffer []byte, offset int, bios *Bios) error {
+	table := AtomTongaPowertuneTable{}
+	if err := unpackAt(buffer, offset, &table); err != nil {
+		return err
+	}
+	bios.AtomPowertuneTable = AtomPowertuneTable{
+		RevID:                     table.RevID,
+		TDP:                       table.TDP,
+		ConfigurableTDP:           table.ConfigurableTDP,
+		TDC:                       table.TDC,
+		BatteryPowerLimit:         table.BatteryPowerLimit,
+		SmallPowerLimit:           table.SmallPowerLimit,
+		LowCACLeakage:             table.LowCACLeakage,
+		HighCACLeakage:            table.HighCACLeakage,
+		MaximumPowerDeliveryLimit: table.MaximumPowerDeliveryLimit,
+		TjMax:                     table.TjMax,
+		PowerTuneDataSetID:        table.PowerTuneDataSetID,
+		EDCLimit:                  table.EDCLimit,
+		SoftwareShutdownTemp:      table.SoftwareShutdownTemp,
+		ClockStretchAmount:        table.ClockStretchAmount,
+	}
+	return nil
+}
+
+func decodeTongaFan(buffer []byte, offset int, bios *Bios) error {
+	table := AtomTongaFanTable{}
+	if err := unpackAt(buffer, offset, &table); err != nil {
+		return err
+	}
+	bios.AtomFanTable = AtomFanTable{
+		RevID:                   table.RevID,
+		THyst:                   table.THyst,
+		TMin:                    table.TMin,
+		TMed:                    table.TMed,
+		THigh:                   table.THigh,
+		PWMMin:                  table.PWMMin,
+		PWMMed:                  table.PWMMed,
+		PWMHigh:                 table.PWMHigh,
+		TMax:                    table.TMax,
+		FanControlMode:          table.FanControlMode,
+		FanPWMMax:               table.FanPWMMax,
+		FanOutputSensitivity:    table.FanOutputSensitivity,
+		FanRPMMax:               table.FanRPMMax,
+		MinFanSCLKAcousticLimit: table.MinFanSCLKAcousticLimit,
+		TargetTemperature:       table.TargetTemperature,
+		MinimumPWMLimit:         table.MinimumPWMLimit,
+	}
+	return nil
+}
+
+// AtomPowertuneTable and AtomFanTable have the size of the Fiji tables. The
+// Polaris tables append fields to the Fiji layout that are not decoded, so a
+// Polaris table is read up to the end of the Fiji layout and nothing past the
+// end of a Fiji table is read.
+func decodeFijiPowertune(buffer []byte, offset int, bios *Bios) error {
+	return unpackAt(buffer, offset, &bios.AtomPowertuneTable)
+}
+
+func decodeFijiFan(buffer []byte, offset int, bios *Bios) error {
+	return unpackAt(buffer, offset, &bios.AtomFanTable)
+}
+
+func decodeTongaSclk(buffer []byte, offset int, bios *Bios) error {
+	table := AtomTongaSClkTable{}
+	if err := unpackAt(buffer, offset, &table); err != nil {
+		return err
+	}
+	bios.AtomSClkTable = AtomSClkTable{
+		RevID:      table.RevID,
+		NumEntries: table.NumEntries,
+		Entries:    make([]AtomSClkEntry, len(table.Entries)),
+	}
+	for i, entry := range table.Entries {
+		bios.AtomSClkTable.Entries[i] = AtomSClkEntry{
+			VddInd:                 entry.VddInd,
+			VddcOffset:             entry.VddcOffset,
+			Sclk:                   entry.Sclk,
+			EdcCurrent:             entry.EdcCurrent,
+			ReliabilityTemperature: entry.ReliabilityTemperature,
+			CKSVOffsetandDisable:   entry.CKSVOffsetandDisable,
+		}
+	}
+	return nil
+}
+
+func decodePolarisSclk(buffer []byte, offset int, bios *Bios) error {
+	return unpackAt(buffer, offset, &bios.AtomSClkTable)
+}
+
+func decodeTongaMclk(buffer []byte, offset int, bios *Bios) error {
+	return unpackAt(buffer, offset, &bios.AtomMClkTable)
+}
+
+func decodeTongaVoltageLookup(buffer []byte, offset int, bios *Bios) error {
+	return unpackAt(buffer, offset, &bios.AtomVoltageTable)
+}
+
+func decodeTongaHardLimit(buffer []byte, offset int, bios *Bios) error {
+	return unpackAt(buffer, offset, &bios.AtomHardLimitTable)
+}
+
+func decodeTongaMMDependency(buffer []byte, offset int, bios *Bios) error {
+	return unpackAt(buffer, offset, &bios.AtomMMDependencyTable)
+}
+
+// decodeVoltageObjectInfoV31 walks the voltage objects, each starts with a
+// header holding its size. The voltages of the LUT modes are kept, the other
+// modes have none.
+func decodeVoltageObjectInfoV31(buffer []byte, offset int, bios *Bios) error {
+	header := AtomCommonTableHeader{}
+	if err := unpackAt(buffer, offset, &header); err != nil {
+		return err
+	}
+	end := offset + int(uint16(header.StructureSize))
+	objects := []VoltageObject{}
+	for objectOffset := offset + 4; objectOffset+4 <= end; {
+		object := VoltageObject{Offset: objectOffset}
+		if err := unpackAt(buffer, objectOffset, &object.Header); err != nil {
+			return err
+		}
+		if object.Header.Size == 0 {
+			break
+		}
+		switch object.Header.VoltageMode {
+		case VoltageModeGPIOLUT, VoltageModePhaseLUT:
+			gpio := AtomGPIOVoltageObject{}
+			if err := unpackAt(buffer, objectOffset, &gpio); err != nil {
+				return fmt.Errorf("voltage object at 0x%x: %s", objectOffset, err)
+			}
+			for _, entry := range gpio.Entries {
+				object.Voltages = append(object.Voltages, entry.VoltageValue)
+			}
+		}
+		objects = append(objects, object)
+		objectOffset += int(object.Header.Size)
+	}
+	bios.VoltageObjects = objects
+	return nil
+}
+
+func decodeVRAMInfoV21(buffer []byte, offset int, bios *Bios) error {
+	vramInfo := AtomVRAMInfo{}
+	if err := unpackAt(buffer, offset, &vramInfo); err != nil {
+		return err
+	}
+	bios.AtomVRAMInfo = vramInfo
+
+	entryOffset := offset + AtomVRAMInfoHeaderSize
+	entries := make([]AtomVRAMEntry, vramInfo.NumOfVRAMModule)
+	for i := range entries {
+		entry := AtomVRAMEntryV7{}
+		if err := unpackAt(buffer, entryOffset, &entry); err != nil {
+			return fmt.Errorf("VRAM entry %d: %s", i, err)
+		}
+		if entry.ModuleSize == 0 {
+			return fmt.Errorf("VRAM entry %d has no size", i)
+		}
+		entries[i] = AtomVRAMEntry{
+			ChannelMapCfg:     entry.ChannelMapCfg,
+			ModuleSize:        entry.ModuleSize,
+			EnableChannels:    entry.EnableChannels,
+			ExtMemoryID:       entry.ExtMemoryID,
+			MemoryType:        entry.MemoryType,
+			ChannelNum:        entry.ChannelNum,
+			ChannelWidth:      entry.ChannelWidth,
+			Density:           entry.Density,
+			Misc:              entry.Misc,
+			VREFI:             entry.VREFI,
+			MemorySize:        uint16(entry.MemorySize) * 16,
+			EMRS2Value:        entry.EMRS2Value,
+			EMRS3Value:        entry.EMRS3Value,
+			MemoryVenderID:    entry.MemoryVenderID,
+			RefreshRateFactor: entry.RefreshRateFactor,
+			FIFODepth:         entry.FIFODepth,
+			CDRBandwidth:      entry.CDRBandwidth,
+			MemPNString:       entry.MemPNString,
+		}
+		entryOffset += int(entry.ModuleSize)
+	}
+	bios.AtomVRAMEntry = entries
+	decodeVRAMSubTables(buffer, offset, vramInfo, bios)
+	return nil
+}
+
+func decodeVRAMInfoV22(buffer []byte, offset int, bios *Bios) error {
+	vramInfo := AtomVRAMInfo{}
+	if err := unpackAt(buffer, offset, &vramInfo); err != nil {
+		return err
+	}
+	bios.AtomVRAMInfo = vramInfo
+
+	entryOffset := offset + AtomVRAMInfoHeaderSize
+	entries := make([]AtomVRAMEntry, vramInfo.NumOfVRAMModule)
+	for i := range entries {
+		if err := unpackAt(buffer, entryOffset, &entries[i]); err != nil {
+			return fmt.Errorf("VRAM entry %d: %s", i, err)
+		}
+		if entries[i].ModuleSize == 0 {
+			return fmt.Errorf("VRAM entry %d has no size", i)
+		}
+		entryOffset += int(entries[i].ModuleSize)
+	}
+	bios.AtomVRAMEntry = entries
+	decodeVRAMSubTables(buffer, offset, vramInfo, bios)
+	return nil
+}
+
+// decodeVRAMSubTables decodes the register tables at their offsets relative to
+// VRAMInfo. A table that fails to decode does not stop the rest. VRAMInfo 2.1
+// has usPerBytePresetOffset and reserved words where 2.2 has the per tile, PHY
+// init and data remap offsets.
+func decodeVRAMSubTables(buffer []byte, offset int, vramInfo AtomVRAMInfo, bios *Bios) {
+	revision := TableRevision{vramInfo.Header.TableFormatRevision, vramInfo.Header.TableContentRevision}
+	subTables := []subTable{
+		{"MemAdjust", vramInfo.MemAdjustTblOffset},
+		{"MemClkPatch", vramInfo.MemClkPatchTblOffset},
+	}
+	if revision == (TableRevision{2, 2}) {
+		subTables = append(subTables,
+			subTable{"McAdjustPerTile", vramInfo.McAdjustPerTileTblOffset},
+			subTable{"McPhyInit", vramInfo.McPhyInitTableOffset},
+			subTable{"DramDataRemap", vramInfo.DramDataRemapTblOffset},
+		)
+	}
+	for _, subTable := range subTables {
+		if subTable.offset == 0 {
+			continue
+		}
+		if err := decodeTable(subTable.name, revision, buffer, offset+int(subTable.offset), bios); err != nil {
+			warnTable(err)
+		}
+	}
+}
+
+// decodeInitRegBlock decodes an ATOM_INIT_REG_BLOCK: the register index list,
+// ended by AtomEndOfRegIndexBlock, followed by data blocks of the same size,
+// ended by a zero MemoryID. Registers that repeat the previous value have no
+// data in the blocks, they are filled in so every block has a value per
+// register.
+func decodeInitRegBlock(buffer []byte, offset int, block *AtomInitRegBlock) error {
+	header := AtomInitRegBlockHeader{}
+	if err := unpackAt(buffer, offset, &header); err != nil {
+		return err
+	}
+	indexOffset := offset + 4
+	registers, values, err := decodeRegIndexList(buffer, indexOffset, header)
+	if err != nil {
+		return err
+	}
+	if int(header.RegDataBlkSize) != 4+values*4 {
+		return fmt.Errorf("data block size %d does not match %d register values", header.RegDataBlkSize, values)
+	}
+
+	blocks := []AtomInitRegDataBlock{}
+	dataOffset := indexOffset + int(header.RegIndexTblSize)
+	for {
+		if dataOffset+4 > len(buffer) {
+			return fmt.Errorf("data block %d at 0x%x is out of range", len(blocks), dataOffset)
+		}
+		memoryID := binary.LittleEndian.Uint32(buffer[dataOffset:])
+		if memoryID == AtomEndOfRegDataBlock {
+			break
+		}
+		if dataOffset+int(header.RegDataBlkSize) > len(buffer) {
+			return fmt.Errorf("data block %d at 0x%x is out of range", len(blocks), dataOffset)
+		}
+		data := make([]uint32, len(registers))
+		value := dataOffset + 4
+		for i, register := range registers {
+			if register.PreRegDataLength&AtomRegDataLengthMask == AtomRegDataSameAsPrevious {
+				if i > 0 {
+					data[i] = data[i-1]
+				}
+				continue
+			}
+			data[i] = binary.LittleEndian.Uint32(buffer[value:])
+			value += 4
+		}
+		blocks = append(blocks, AtomInitRegDataBlock{memoryID, data})
+		dataOffset += int(header.RegDataBlkSize)
+	}
+	block.Registers = registers
+	block.Blocks = blocks
+	return nil
+}
+
+func decodeMemAdjust(buffer []byte, offset int, bios *Bios) error {
+	return decodeInitRegBlock(buffer, offset, &bios.AtomMemAdjustTable)
+}
+
+func decodeMcAdjustPerTile(buffer []byte, offset int, bios *Bios) error {
+	return decodeInitRegBlock(buffer, offset, &bios.AtomMcAdjustPerTileTable)
+}
+
+func decodeMcPhyInit(buffer []byte, offset int, bios *Bios) error {
+	return decodeInitRegBlock(buffer, offset, &bios.AtomMcPhyInitTable)
+}
+
+// decodeRegIndexList decodes the register index list of an
+// ATOM_INIT_REG_BLOCK at offset and counts the registers with a value of their
+// own in the data blocks.
+func decodeRegIndexList(buffer []byte, offset int, header AtomInitRegBlockHeader) ([]AtomInitRegIndex, int, error) {
+	registers := []AtomInitRegIndex{}
+	values := 0
+	for i := 0; i < int(header.RegIndexTblSize)/3; i++ {
+		register := AtomInitRegIndex{}
+		if err := unpackAt(buffer, offset+i*3, &register); err != nil {
+			return nil, 0, err
+		}
+		if register.RegIndex == AtomEndOfRegIndexBlock || register.PreRegDataLength&AtomRegAccessPlaceholder != 0 {
+			break
+		}
+		if register.PreRegDataLength&AtomRegDataLengthMask != AtomRegDataSameAsPrevious {
+			values++
+		}
+		registers = append(registers, register)
+	}
+	return registers, values, nil
+}
+
+// decodeMemClkPatch decodes the timing straps, the data blocks of the memory
+// clock patch register table. Every block holds the strap of a module and
+// clock range. The register index list says which registers the strap holds,
+// it is kept so straps are only copied between tables with the same list.
+func decodeMemClkPatch(buffer []byte, offset int, bios *Bios) error {
+	header := AtomInitRegBlockHeader{}
+	if err := unpackAt(buffer, offset, &header); err != nil {
+		return err
+	}
+	if header.RegDataBlkSize != AtomVRAMTimingEntrySize {
+		return fmt.Errorf("strap size %d is not supported", header.RegDataBlkSize)
+	}
+	registers, _, err := decodeRegIndexList(buffer, offset+4, header)
+	if err != nil {
+		return err
+	}
+	entries := []AtomVRAMTimingEntry{}
+	entryOffset := offset + 4 + int(header.RegIndexTblSize)
+	for {
+		if entryOffset+4 > len(buffer) {
+			return fmt.Errorf("strap %d at 0x%x is out of range", len(entries), entryOffset)
+		}
+		if binary.LittleEndian.Uint32(buffer[entryOffset:]) == AtomEndOfRegDataBlock {
+			break
+		}
+		entry := AtomVRAMTimingEntry{}
+		if err := unpackAt(buffer, entryOffset, &entry); err != nil {
+			return fmt.Errorf("strap %d: %s", len(entries), err)
+		}
+		entries = append(entries, entry)
+		entryOffset += AtomVRAMTimingEntrySize
+	}
+	bios.AtomVRAMTimingRegisters = registers
+	bios.AtomVRAMTimingEntry = entries
+	return nil
+}
+
+func decodeDramDataRemap(buffer []byte, offset int, bios *Bios) error {
+	entries := make([]AtomDramDataRemap, bios.AtomVRAMInfo.NumOfVRAMModule)
+	for i := range entries {
+		if err := unpackAt(buffer, offset+i*AtomDramDataRemapSize, &entries[i]); err != nil {
+			return fmt.Errorf("remap entry %d: %s", i, err)
+		}
+	}
+	bios.AtomDramDataRemapTable.Entries = entries
+	return nil
+}
+
+// The Vega 10 tables are kept in their own structures. The overdrive limits
+// are shared with the v7 PowerPlay table so the generic views keep working.
+func decodeVega10Powerplay(buffer []byte, offset int, bios *Bios) error {
+	table := AtomVega10PowerplayTable{}
+	if err := unpackAt(buffer, offset, &table); err != nil {
+		return err
+	}
+	bios.AtomVega10PowerplayTable = table
+	bios.AtomPowerplayTable = AtomPowerplayTable{
+		Header:            table.Header,
+		TableRevision:     table.TableRevision,
+		TableSize:         table.TableSize,
+		GoldenPPID:        table.GoldenPPID,
+		GoldenRevision:    table.GoldenRevision,
+		FormatID:          table.FormatID,
+		PlatformCaps:      table.PlatformCaps,
+		MaxODEngineClock:  table.MaxODEngineClock,
+		MaxODMemoryClock:  table.MaxODMemoryClock,
+		PowerControlLimit: table.PowerControlLimit,
+		UlvVoltageOffset:  table.UlvVoltageOffset,
+	}
+
+	subTables := []subTable{
+		{"GfxclkDependency", table.GfxclkDependencyTableOffset},
+		{"SocclkDependency", table.SocclkDependencyTableOffset},
+		{"MclkDependency", table.MclkDependencyTableOffset},
+		{"DcefclkDependency", table.DcefclkDependencyTableOffset},
+		{"VddcLookup", table.VddcLookupTableOffset},
+		{"FanTable", table.FanTableOffset},
+		{"PowerTuneTable", table.PowerTuneTableOffset},
+	}
+	decodeSubTables(buffer, offset, table.Header.TableFormatRevision, subTables, bios)
+	return nil
+}
+
+func decodeVega10GfxClkV1(buffer []byte, offset int, bios *Bios) error {
+	table := AtomVega10GfxClkTableV1{}
+	if err := unpackAt(buffer, offset, &table); err != nil {
+		return err
+	}
+	bios.AtomVega10GfxClkTable = AtomVega10GfxClkTable{
+		RevID:      table.RevID,
+		NumEntries: table.NumEntries,
+		Entries:    make([]AtomVega10GfxClkEntry, len(table.Entries)),
+	}
+	for i, entry := range table.Entries {
+		bios.AtomVega10GfxClkTable.Entries[i] = AtomVega10GfxClkEntry{
+			Clk:                  entry.Clk,
+			VddInd:               entry.VddInd,
+			CKSVOffsetandDisable: entry.CKSVOffsetandDisable,
+			AVFSOffset:           entry.AVFSOffset,
+		}
+	}
+	return nil
+}
+
+func decodeVega10GfxClk(buffer []byte, offset int, bios *Bios) error {
+	return unpackAt(buffer, offset, &bios.AtomVega10GfxClkTable)
+}
+
+func decodeVega10SocClk(buffer []byte, offset int, bios *Bios) error {
+	return unpackAt(buffer, offset, &bios.AtomVega10SocClkTable)
+}
+
+func decodeVega10DcefClk(buffer []byte, offset int, bios *Bios) error {
+	return unpackAt(buffer, offset, &bios.AtomVega10DcefClkTable)
+}
+
+func decodeVega10MClk(buffer []byte, offset int, bios *Bios) error {
+	return unpackAt(buffer, offset, &bios.AtomVega10MClkTable)
+}
+
+func decodeVega10VoltageLookup(buffer []byte, offset int, bios *Bios) error {
+	return unpackAt(buffer, offset, &bios.AtomVega10VddcTable)
+}
+
+func decodeVega10Fan(buffer []byte, offset int, bios *Bios) error {
+	return unpackAt(buffer, offset, &bios.AtomVega10FanTable)
+}
+
+func decodeVega10Powertune(buffer []byte, offset int, bios *Bios) error {
+	return unpackAt(buffer, offset, &bios.AtomVega10PowertuneTable)
+}
+
+func decodeMCInitParameterV11(buffer []byte, offset int, bios *Bios) error {
+	table := AtomMCInitParamTable{}
+	if err := unpackAt(buffer, offset, &table); err != nil {
+		return err
+	}
+	param := MCInitParameter{ARBSEQData: table.ARBSEQData}
+	if table.MCInitMemTypeTblOffset != 0 {
+		if err := decodeInitRegBlock(buffer, offset+int(table.MCInitMemTypeTblOffset), &param.MemType); err != nil {
+			return fmt.Errorf("memory type registers: %s", err)
+		}
+	}
+	if table.MCInitCommonTblOffset != 0 {
+		if err := decodeInitRegBlock(buffer, offset+int(table.MCInitCommonTblOffset), &param.Common); err != nil {
+			return fmt.Errorf("common registers: %s", err)
+		}
+	}
+	bios.MCInitParameter = param
+	return nil
+}
+
+func decodeMCInitParameterV21(buffer []byte, offset int, bios *Bios) error {
+	table := AtomMCInitParamTableV21{}
+	if err := unpackAt(buffer, offset, &table); err != nil {
+		return err
+	}
+	param := MCInitParameter{
+		MCUcodeVersion: table.MCUcodeVersion,
+		MCUcodeLength:  table.MCUcodeLength,
+	}
+	if table.McRegInitTableOffset != 0 {
+		settings, err := decodeRegInitSettings(buffer, offset+int(table.McRegInitTableOffset), AtomMaxRegInitSettings*6)
+		if err != nil {
+			return fmt.Errorf("register settings: %s", err)
+		}
+		param.RegInit = settings
+	}
+	bios.MCInitParameter = param
+	return nil
+}
+
+func decodeMemoryTrainingInfoV21(buffer []byte, offset int, bios *Bios) error {
+	table := AtomMemoryTrainingInfo{}
+	if err := unpackAt(buffer, offset, &table); err != nil {
+		return err
+	}
+	training := MemoryTraining{TrainingLoop: table.TrainingLoop}
+	if err := decodeInitRegBlock(buffer, offset+8, &training.Settings); err != nil {
+		return fmt.Errorf("training settings: %s", err)
+	}
+	bios.MemoryTraining = training
+	return nil
+}
+
+func decodeMemoryTrainingInfoV31(buffer []byte, offset int, bios *Bios) error {
+	table := AtomMemoryTrainingInfoV31{}
+	if err := unpackAt(buffer, offset, &table); err != nil {
+		return err
+	}
+	training := MemoryTraining{
+		MCUcodeVersion: table.MCUcodeVersion,
+		MCUcodeLength:  table.MCUcodeLen,
+	}
+	if table.MCIORegInitOffset != 0 {
+		settings, err := decodeRegInitSettings(buffer, offset+int(table.MCIORegInitOffset), int(table.MCIOInitLen))
+		if err != nil {
+			return fmt.Errorf("MC IO settings: %s", err)
+		}
+		training.IOInit = settings
+	}
+	bios.MemoryTraining = training
+	return nil
+}
+
+// decodeRegInitSettings decodes an ATOM_REG_INIT_SETTING array of up to size
+// bytes, it also ends at an index of 0 or AtomEndOfRegIndexBlock.
+func decodeRegInitSettings(buffer []byte, offset int, size int) ([]AtomRegInitSetting, error) {
+	settings := []AtomRegInitSetting{}
+	for i := 0; i < size/6; i++ {
+		setting := AtomRegInitSetting{}
+		if err := unpackAt(buffer, offset+i*6, &setting); err != nil {
+			return nil, err
+		}
+		if setting.RegIndex == 0 || setting.RegIndex == AtomEndOfRegIndexBlock {
+			break
+		}
+		settings = append(settings, setting)
+	}
+	return settings, nil
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ttacon/chalk"
+)
+
+// strapField is a timing in a Polaris strap, Bits wide at Shift of a dword.
+type strapField struct {
+	Name     string
+	Register string
+	Dword    int
+	Shift    uint
+	Bits     uint
+}
+
+// polarisStrapFields are the timings of the 48 byte GDDR5 strap of Polaris:
+// SEQ_WR_CTL_D1, SEQ_WR_CTL_2, SEQ_PMG_TIMING, SEQ_RAS_TIMING, SEQ_CAS_TIMING,
+// SEQ_MISC_TIMING, SEQ_MISC_TIMING2, SEQ_MISC1 (MR0 and MR1), SEQ_MISC3,
+// SEQ_MISC8, ARB_DRAM_TIMING and ARB_DRAM_TIMING2. The sequencer timings are
+// in memory clock cycles. The sequencer has no tRAS, the arbiter keeps it as
+// RASMACTRD.
+var polarisStrapFields = []strapField{
+	{"tCKSRE", "SEQ_PMG_TIMING", 2, 0, 3},
+	{"tCKSRX", "SEQ_PMG_TIMING", 2, 4, 3},
+	{"tCKE_PULSE", "SEQ_PMG_TIMING", 2, 8, 4},
+	{"tCKE", "SEQ_PMG_TIMING", 2, 12, 6},
+	{"tRCDW", "SEQ_RAS_TIMING", 3, 0, 5},
+	{"tRCDWA", "SEQ_RAS_TIMING", 3, 5, 5},
+	{"tRCD", "SEQ_RAS_TIMING", 3, 10, 5},
+	{"tRCDRA", "SEQ_RAS_TIMING", 3, 15, 5},
+	{"tRRD", "SEQ_RAS_TIMING", 3, 20, 4},
+	{"tRC", "SEQ_RAS_TIMING", 3, 24, 7},
+	{"tNOPW", "SEQ_CAS_TIMING", 4, 0, 2},
+	{"tNOPR", "SEQ_CAS_TIMING", 4, 2, 2},
+	{"tR2W", "SEQ_CAS_TIMING", 4, 4, 5},
+	{"tCCDL", "SEQ_CAS_TIMING", 4, 9, 3},
+	{"tR2R", "SEQ_CAS_TIMING", 4, 12, 4},
+	{"tW2R", "SEQ_CAS_TIMING", 4, 16, 5},
+	{"CL", "SEQ_CAS_TIMING", 4, 24, 5},
+	{"tRP_WRA", "SEQ_MISC_TIMING", 5, 0, 6},
+	{"tRP_RDA", "SEQ_MISC_TIMING", 5, 8, 6},
+	{"tRP", "SEQ_MISC_TIMING", 5, 15, 5},
+	{"tRFC", "SEQ_MISC_TIMING", 5, 20, 9},
+	{"PA2RDATA", "SEQ_MISC_TIMING2", 6, 0, 3},
+	{"PA2WDATA", "SEQ_MISC_TIMING2", 6, 4, 3},
+	{"tFAW", "SEQ_MISC_TIMING2", 6, 8, 5},
+	{"tCRCRL", "SEQ_MISC_TIMING2", 6, 13, 3},
+	{"tCRCWL", "SEQ_MISC_TIMING2", 6, 16, 5},
+	{"tFAW32", "SEQ_MISC_TIMING2", 6, 24, 8},
+	{"WL", "SEQ_MISC1", 7, 0, 3},
+	{"ACTRD", "ARB_DRAM_TIMING", 10, 0, 8},
+	{"ACTWR", "ARB_DRAM_TIMING", 10, 8, 8},
+	{"tRAS", "ARB_DRAM_TIMING", 10, 16, 8},
+	{"RASMACTWR", "ARB_DRAM_TIMING", 10, 24, 8},
+	{"RAS2RAS", "ARB_DRAM_TIMING2", 11, 0, 8},
+	{"RP", "ARB_DRAM_TIMING2", 11, 8, 8},
+	{"WRPLUSRP", "ARB_DRAM_TIMING2", 11, 16, 8},
+	{"BUS_TURN", "ARB_DRAM_TIMING2", 11, 24, 8},
+}
+
+// polarisStrapRegisters are the registers of the Polaris strap in the order of
+// its dwords. The fields above are only where they are if the register index
+// list of the strap table names these registers.
+var polarisStrapRegisters = []string{
+	"MC_SEQ_WR_CTL_D1",
+	"MC_SEQ_WR_CTL_2",
+	"MC_SEQ_PMG_TIMING",
+	"MC_SEQ_RAS_TIMING",
+	"MC_SEQ_CAS_TIMING",
+	"MC_SEQ_MISC_TIMING",
+	"MC_SEQ_MISC_TIMING2",
+	"MC_SEQ_MISC1",
+	"MC_SEQ_MISC3",
+	"MC_SEQ_MISC8",
+	"MC_ARB_DRAM_TIMING",
+	"MC_ARB_DRAM_TIMING2",
+}
+
+// checkStrapLayout returns an error unless the straps of a bios have the
+// Polaris layout, a Polaris bios whose register index list names the
+// polarisStrapRegisters. Registers that take the value of the previous one
+// have no dword in the strap.
+func checkStrapLayout(bios Bios) error {
+	if bios.Family != FamilyPolaris {
+		return fmt.Errorf("only Polaris straps can be decoded, the bios is %s", bios.Family)
+	}
+	registers := []string{}
+	for _, register := range bios.AtomVRAMTimingRegisters {
+		if register.PreRegDataLength&AtomRegDataLengthMask != AtomRegDataSameAsPrevious {
+			registers = append(registers, mcRegisterName(register))
+		}
+	}
+	for i, register := range registers {
+		if i < len(polarisStrapRegisters) && register != polarisStrapRegisters[i] {
+			return fmt.Errorf("strap register %d is %s, not %s as in Polaris straps", i, register, polarisStrapRegisters[i])
+		}
+	}
+	if len(registers) != len(polarisStrapRegisters) {
+		return fmt.Errorf("the straps hold %d registers, Polaris straps %d", len(registers), len(polarisStrapRegisters))
+	}
+	return nil
+}
+
+// GDDR5 transfers a burst of 8 in 2 memory clock cycles.
+const gddr5BurstCycles = 2
+
+// gddr5RefreshTime is the minimum tRFC in ns by chip density in Mbit.
+var gddr5RefreshTime = map[int]uint32{
+	1024: 55,
+	2048: 65,
+	4096: 65,
+	8192: 75,
+}
+
+// strapTiming returns a timing of a Polaris strap by name.
+func strapTiming(latency [0x30]byte, name string) uint32 {
+	for _, field := range polarisStrapFields {
+		if field.Name == name {
+			offset := field.Dword * 4
+			dword := uint32(latency[offset]) | uint32(latency[offset+1])<<8 | uint32(latency[offset+2])<<16 | uint32(latency[offset+3])<<24
+			return (dword >> field.Shift) & (1<<field.Bits - 1)
+		}
+	}
+	return 0
+}
+
+// validateStrap checks the timings of a GDDR5 strap at its clock in Mhz. A
+// tFAW of 0 turns the four activate window off.
+func validateStrap(latency [0x30]byte, clock uint32, density int) []string {
+	t := func(name string) uint32 {
+		return strapTiming(latency, name)
+	}
+	problems := []string{}
+	if t("tRAS") < t("tRCD")+gddr5BurstCycles {
+		problems = append(problems, fmt.Sprintf("tRAS %d is below tRCD %d + burst %d", t("tRAS"), t("tRCD"), gddr5BurstCycles))
+	}
+	if t("tRC") < t("tRAS")+t("tRP") {
+		problems = append(problems, fmt.Sprintf("tRC %d is below tRAS %d + tRP %d", t("tRC"), t("tRAS"), t("tRP")))
+	}
+	if ns, found := gddr5RefreshTime[density]; found {
+		// Cycles for ns at the clock in Mhz, rounded up.
+		min := (ns*clock + 999) / 1000
+		if t("tRFC") < min {
+			problems = append(problems, fmt.Sprintf("tRFC %d is below %d, %d ns for %d Mbit chips at %d Mhz", t("tRFC"), min, ns, density, clock))
+		}
+	}
+	if t("tFAW") != 0 && t("tFAW") < 4*t("tRRD") {
+		problems = append(problems, fmt.Sprintf("tFAW %d is below 4 x tRRD %d", t("tFAW"), t("tRRD")))
+	}
+	return problems
+}
+
+// validateStraps checks the straps of the GDDR5 modules of a bios with the
+// Polaris strap layout, only the straps of changes if it is not nil. Straps of
+// another layout or memory type are returned as unchecked, with the reason.
+func validateStraps(bios Bios, changes []StrapChange) ([]string, []string) {
+	problems := []string{}
+	unchecked := []string{}
+	checked := map[StrapChange]bool{}
+	for _, change := range changes {
+		checked[StrapChange{change.Module, change.Clock, false}] = true
+	}
+	layout := checkStrapLayout(bios)
+	for _, entry := range bios.AtomVRAMTimingEntry {
+		module, clock := strapModule(entry), strapClock(entry)
+		if changes != nil && !checked[StrapChange{module, clock, false}] {
+			continue
+		}
+		strap := fmt.Sprintf("%s, %d Mhz", formatModule(module, bios.AtomVRAMEntry), clock)
+		if layout != nil {
+			unchecked = append(unchecked, strap+": "+layout.Error())
+			continue
+		}
+		if int(module) >= len(bios.AtomVRAMEntry) || bios.AtomVRAMEntry[module].MemoryType != MemoryTypeGDDR5 {
+			unchecked = append(unchecked, strap+": not GDDR5")
+			continue
+		}
+		density := vramDensityMbit(bios.AtomVRAMEntry[module].Density)
+		for _, problem := range validateStrap(entry.Latency, clock, density) {
+			problems = append(problems, strap+": "+problem)
+		}
+	}
+	return problems, unchecked
+}
+
+// checkStraps shows the problems of the changed straps before they are
+// written, and the straps that can not be checked. Without force either stops
+// the write.
+func checkStraps(bios Bios, changes []StrapChange, force bool) {
+	problems, unchecked := validateStraps(bios, changes)
+	if len(problems) == 0 && len(unchecked) == 0 {
+		return
+	}
+	color := chalk.Yellow
+	if !force {
+		color = chalk.Red
+	}
+	for _, strap := range unchecked {
+		fmt.Println(color, strap+", not checked", chalk.Reset)
+	}
+	for _, problem := range problems {
+		fmt.Println(color, problem, chalk.Reset)
+	}
+	if force {
+		return
+	}
+	if len(problems) > 0 {
+		fmt.Println(chalk.Red, "The straps break GDDR5 timing rules, pass --force to write them anyway.", chalk.Reset)
+	} else {
+		fmt.Println(chalk.Red, "The straps can not be checked against the GDDR5 timing rules, pass --force to write them unchecked.", chalk.Reset)
+	}
+	os.Exit(1)
+}
+
+// validateTimings checks every strap of a bios.
+func validateTimings(filename string) {
+	_, bios := openStraps(filename)
+	if err := checkStrapLayout(bios); err != nil {
+		fmt.Println(chalk.Yellow, "The straps can not be checked,", err.Error()+".", chalk.Reset)
+		return
+	}
+	problems, unchecked := validateStraps(bios, nil)
+	for _, strap := range unchecked {
+		fmt.Println(chalk.Yellow, strap+", not checked", chalk.Reset)
+	}
+	for _, problem := range problems {
+		fmt.Println(chalk.Yellow, problem, chalk.Reset)
+	}
+	if len(problems) == 0 {
+		fmt.Println(chalk.Green, len(bios.AtomVRAMTimingEntry)-len(unchecked), "straps checked, no problems found.", chalk.Reset)
+	}
+}
+
+// matrixTimings are the timings shown by timings matrix.
+var matrixTimings = []string{"tRCD", "tRP", "tRAS", "tRC", "tRFC", "CL", "WL"}
+
+// displayTimingMatrix shows the key timings of every strap, a table per module
+// with a column per clock. With against, timings that differ from the strap of
+// the same module and clock in against are highlighted with the other value
+// after the slash, straps against lacks are shown in yellow. Straps that
+// differ in bytes outside the shown timings and straps or modules that only
+// one of the bioses has are listed below the table, each counts as a
+// difference.
+func displayTimingMatrix(filename string, againstFile string) {
+	_, bios := openStraps(filename)
+	var against *Bios
+	if againstFile != "" {
+		_, other := openStraps(againstFile)
+		against = &other
+	}
+	for _, b := range []*Bios{&bios, against} {
+		if b == nil {
+			continue
+		}
+		if err := checkStrapLayout(*b); err != nil {
+			fmt.Println(chalk.Red, "The straps can not be decoded,", err.Error()+".", chalk.Reset)
+			os.Exit(1)
+		}
+	}
+
+	modules := len(bios.AtomVRAMEntry)
+	if against != nil && len(against.AtomVRAMEntry) > modules {
+		modules = len(against.AtomVRAMEntry)
+	}
+	differences := 0
+	for module := 0; module < modules; module++ {
+		straps := moduleStraps(bios, uint32(module))
+		fmt.Printf("\n%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+		if module < len(bios.AtomVRAMEntry) {
+			fmt.Printf("%s%s%s\n", chalk.Blue, formatModule(uint32(module), bios.AtomVRAMEntry), chalk.Reset)
+		} else {
+			fmt.Printf("%s%s%s\n", chalk.Blue, formatModule(uint32(module), against.AtomVRAMEntry), chalk.Reset)
+		}
+		fmt.Printf("%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+		if against != nil && module >= len(bios.AtomVRAMEntry) {
+			fmt.Println(chalk.Yellow, "Module only in "+againstFile+".", chalk.Reset)
+			differences += len(moduleStraps(*against, uint32(module)))
+			continue
+		}
+		if against != nil && module >= len(against.AtomVRAMEntry) {
+			fmt.Println(chalk.Yellow, "Module not in "+againstFile+".", chalk.Reset)
+		}
+		if len(straps) == 0 {
+			fmt.Println(chalk.Yellow, "No straps.", chalk.Reset)
+		} else {
+			displayStrapMatrix(straps, uint32(module), against)
+		}
+		if against != nil {
+			differences += displayStrapDifferences(straps, moduleStraps(*against, uint32(module)), againstFile)
+		}
+	}
+	if against != nil {
+		fmt.Printf("\n%s%s%s%d%s\n", chalk.Bold, "Straps that differ from "+againstFile+": ", chalk.White, differences, chalk.Reset)
+	}
+}
+
+// moduleStraps returns the straps of a module in table order.
+func moduleStraps(bios Bios, module uint32) []AtomVRAMTimingEntry {
+	straps := []AtomVRAMTimingEntry{}
+	for _, entry := range bios.AtomVRAMTimingEntry {
+		if strapModule(entry) == module {
+			straps = append(straps, entry)
+		}
+	}
+	return straps
+}
+
+// displayStrapMatrix shows the matrixTimings of the straps of a module.
+func displayStrapMatrix(straps []AtomVRAMTimingEntry, module uint32, against *Bios) {
+	fmt.Printf("%s%-6s", chalk.Bold, "Mhz")
+	for _, strap := range straps {
+		fmt.Printf("%10d", strapClock(strap))
+	}
+	fmt.Printf("%s\n", chalk.Reset)
+	for _, name := range matrixTimings {
+		fmt.Printf("%s%-6s%s", chalk.Bold, name, chalk.Reset)
+		for _, strap := range straps {
+			value := strapTiming(strap.Latency, name)
+			cell := fmt.Sprintf("%10d", value)
+			color := chalk.White
+			if against != nil {
+				if other := findStrap(*against, module, strapClock(strap)); other < 0 {
+					color = chalk.Yellow
+				} else if otherValue := strapTiming(against.AtomVRAMTimingEntry[other].Latency, name); otherValue != value {
+					cell = fmt.Sprintf("%10s", fmt.Sprintf("%d/%d", value, otherValue))
+					color = chalk.Red
+				}
+			}
+			fmt.Printf("%s%s%s", color, cell, chalk.Reset)
+		}
+		fmt.Println()
+	}
+}
+
+// displayStrapDifferences lists the straps of a module that differ from the
+// straps of the same module in against, by byte, and the straps only one side
+// has. It returns the number of straps listed.
+func displayStrapDifferences(straps []AtomVRAMTimingEntry, others []AtomVRAMTimingEntry, againstFile string) int {
+	differences := 0
+	for _, strap := range straps {
+		// The first strap of a clock is the one in use, as in findStrap.
+		other := -1
+		for i := range others {
+			if strapClock(others[i]) == strapClock(strap) {
+				other = i
+				break
+			}
+		}
+		if other < 0 {
+			fmt.Printf("%s%d %s: %s%s%s\n", chalk.Bold, strapClock(strap), "Mhz", chalk.Yellow, "not in "+againstFile, chalk.Reset)
+			differences++
+			continue
+		}
+		bytes := []string{}
+		for i := range strap.Latency {
+			if strap.Latency[i] != others[other].Latency[i] {
+				bytes = append(bytes, fmt.Sprintf("0x%02x", i))
+			}
+		}
+		if len(bytes) > 0 {
+			result := "byte " + bytes[0] + " differs"
+			if len(bytes) > 1 {
+				result = fmt.Sprintf("%d bytes differ at %s", len(bytes), strings.Join(bytes, ", "))
+			}
+			fmt.Printf("%s%d %s: %s%s%s\n", chalk.Bold, strapClock(strap), "Mhz", chalk.Red, result, chalk.Reset)
+			differences++
+		}
+	}
+	for _, other := range others {
+		found := false
+		for _, strap := range straps {
+			found = found || strapClock(strap) == strapClock(other)
+		}
+		if !found {
+			fmt.Printf("%s%d %s: %s%s%s\n", chalk.Bold, strapClock(other), "Mhz", chalk.Yellow, "only in "+againstFile, chalk.Reset)
+			differences++
+		}
+	}
+	return differences
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"testing"
+)
+
+// strapWith builds a Polaris strap that holds the timings.
+func strapWith(timings map[string]uint32) [0x30]byte {
+	latency := [0x30]byte{}
+	for _, field := range polarisStrapFields {
+		value, found := timings[field.Name]
+		if !found {
+			continue
+		}
+		offset := field.Dword * 4
+		for i := uint(0); i < field.Bits; i++ {
+			if value&(1<<i) != 0 {
+				bit := field.Shift + i
+				latency[offset+int(bit/8)] |= 1 << (bit % 8)
+			}
+		}
+	}
+	return latency
+}
+
+// goodTimings pass every rule for 4 Gbit chips at 2000 Mhz.
+func goodTimings(changes map[string]uint32) map[string]uint32 {
+	timings := map[string]uint32{"tRCD": 20, "tRAS": 40, "tRP": 15, "tRC": 60, "tRFC": 200, "tRRD": 6, "tFAW": 0}
+	for name, value := range changes {
+		timings[name] = value
+	}
+	return timings
+}
+
+func TestStrapTiming(t *testing.T) {
+	timings := goodTimings(map[string]uint32{"CL": 21, "WL": 5, "BUS_TURN": 0xab})
+	latency := strapWith(timings)
+	for name, value := range timings {
+		if got := strapTiming(latency, name); got != value {
+			t.Errorf("%s is %d, expected %d", name, got, value)
+		}
+	}
+}
+
+func TestValidateStrap(t *testing.T) {
+	tests := []struct {
+		name     string
+		changes  map[string]uint32
+		clock    uint32
+		density  int
+		problems int
+	}{
+		{"good", nil, 2000, 4096, 0},
+		{"tRAS below tRCD and burst", map[string]uint32{"tRAS": 21}, 2000, 4096, 1},
+		{"tRAS at tRCD and burst", map[string]uint32{"tRAS": 22, "tRC": 40}, 2000, 4096, 0},
+		{"tRC below tRAS and tRP", map[string]uint32{"tRC": 54}, 2000, 4096, 1},
+		{"tRFC below 65 ns at 2000 Mhz", map[string]uint32{"tRFC": 129}, 2000, 4096, 1},
+		{"tRFC at 65 ns at 2000 Mhz", map[string]uint32{"tRFC": 130}, 2000, 4096, 0},
+		{"tRFC at 65 ns at 1750 Mhz", map[string]uint32{"tRFC": 114}, 1750, 4096, 0},
+		{"tRFC below 75 ns for 8 Gbit", map[string]uint32{"tRFC": 131}, 1750, 8192, 1},
+		{"tRFC of unknown density", map[string]uint32{"tRFC": 1}, 2000, 512, 0},
+		{"tFAW off", map[string]uint32{"tFAW": 0}, 2000, 4096, 0},
+		{"tFAW below 4 x tRRD", map[string]uint32{"tFAW": 23}, 2000, 4096, 1},
+		{"tFAW at 4 x tRRD", map[string]uint32{"tFAW": 24}, 2000, 4096, 0},
+		{"every rule", map[string]uint32{"tRAS": 21, "tRC": 30, "tRFC": 100, "tFAW": 10}, 2000, 4096, 4},
+	}
+	for _, test := range tests {
+		problems := validateStrap(strapWith(goodTimings(test.changes)), test.clock, test.density)
+		if len(problems) != test.problems {
+			t.Errorf("%s: %d problems %v, expected %d", test.name, len(problems), problems, test.problems)
+		}
+	}
+}
+
+// polarisRegisters returns the register index list of Polaris straps.
+func polarisRegisters() []AtomInitRegIndex {
+	registers := []AtomInitRegIndex{}
+	for _, name := range polarisStrapRegisters {
+		for index, register := range mcRegisters {
+			if register == name {
+				registers = append(registers, AtomInitRegIndex{index, 4})
+			}
+		}
+	}
+	return registers
+}
+
+func TestCheckStrapLayout(t *testing.T) {
+	polaris := polarisRegisters()
+	// A register that takes the value of the previous one has no dword.
+	withSame := append([]AtomInitRegIndex{polaris[0], {0x0a2f, AtomRegDataSameAsPrevious}}, polaris[1:]...)
+	swapped := append([]AtomInitRegIndex{polaris[1], polaris[0]}, polaris[2:]...)
+
+	tests := []struct {
+		name      string
+		family    string
+		registers []AtomInitRegIndex
+		ok        bool
+	}{
+		{"Polaris", FamilyPolaris, polaris, true},
+		{"same as previous", FamilyPolaris, withSame, true},
+		{"swapped", FamilyPolaris, swapped, false},
+		{"short", FamilyPolaris, polaris[:11], false},
+		{"long", FamilyPolaris, append(append([]AtomInitRegIndex{}, polaris...), AtomInitRegIndex{0x0a2f, 4}), false},
+		{"Tonga", FamilyTonga, polaris, false},
+	}
+	for _, test := range tests {
+		err := checkStrapLayout(Bios{Family: test.family, AtomVRAMTimingRegisters: test.registers})
+		if (err == nil) != test.ok {
+			t.Errorf("%s: error %v", test.name, err)
+		}
+	}
+}
+
+func TestValidateStraps(t *testing.T) {
+	bad := strapWith(goodTimings(map[string]uint32{"tRAS": 21}))
+	bios := Bios{
+		Family:                  FamilyPolaris,
+		AtomVRAMTimingRegisters: polarisRegisters(),
+		AtomVRAMEntry: []AtomVRAMEntry{
+			{MemoryType: MemoryTypeGDDR5, Density: 0x53},
+			{MemoryType: 0, Density: 0x53},
+		},
+		AtomVRAMTimingEntry: []AtomVRAMTimingEntry{
+			{0<<24 | 175000, strapWith(goodTimings(nil))},
+			{0<<24 | 200000, bad},
+			{1<<24 | 200000, bad},
+		},
+	}
+
+	problems, unchecked := validateStraps(bios, nil)
+	if len(problems) != 1 || len(unchecked) != 1 {
+		t.Errorf("problems %v and unchecked %v, expected one of each", problems, unchecked)
+	}
+	problems, unchecked = validateStraps(bios, []StrapChange{{0, 1750, true}})
+	if len(problems) != 0 || len(unchecked) != 0 {
+		t.Errorf("problems %v and unchecked %v of a good strap", problems, unchecked)
+	}
+
+	bios.AtomVRAMTimingRegisters = bios.AtomVRAMTimingRegisters[1:]
+	problems, unchecked = validateStraps(bios, nil)
+	if len(problems) != 0 || len(unchecked) != 3 {
+		t.Errorf("problems %v and unchecked %v with other registers, expected every strap unchecked", problems, unchecked)
+	}
+}
@@ -3,7 +3,9 @@ package main
 const (
 	AtomROMChecksumOffset 	= 0x21
 	AtomROMHeaderPtr      	= 0x48
+	AtomROMPCIRPtr      	= 0x18
 	AtomMaxVRAMEntries 	= 24
+	AtomVRAMInfoHeaderSize 	= 20
 
 	MemoryTypeGDDR1 	= 0x10
 	MemoryTypeDDR2 		= 0x20
@@ -12,6 +14,27 @@ const (
 	MemoryTypeGDDR5 	= 0x50
 	MemoryTypeHBM 		= 0x60
 	MemoryTypeDDR3 		= 0xB0
+
+	VoltageTypeVDDC 	= 1
+	VoltageTypeMVDDC 	= 2
+	VoltageTypeMVDDQ 	= 3
+	VoltageTypeVDDCI 	= 4
+	VoltageTypeVDDGFX 	= 5
+
+	VoltageModeGPIOLUT 	= 0
+	VoltageModePhaseLUT = 4
+	VoltageModeSVID2 	= 7
+
+	AtomEndOfRegIndexBlock 	= 0xffff
+	AtomEndOfRegDataBlock 	= 0
+	AtomRegAccessPlaceholder = 0x80
+	AtomRegAccessMCIODebug 	= 0x40
+	AtomRegDataLengthMask 	= 0x0f
+	AtomRegDataSameAsPrevious = 0
+	AtomMemClockRangeMask 	= 0xffffff
+	AtomDramDataRemapSize 	= 42
+	AtomMaxRegInitSettings 	= 1024
+	AtomVRAMTimingEntrySize = 0x34
 )
 
 var vramVendors = map[byte]string{
@@ -51,19 +74,30 @@ var vramDensity = map[byte]string{
 	0x63 : "256M x 32",
 	0x71 : "512M x 8",
 	0x72 : "512M x 16",
+	0x73 : "512M x 32",
+	0x81 : "1G x 8",
+	0x82 : "1G x 16",
+	0x83 : "1G x 32",
 }
 
+// AMD cards never used GDDR5X, the AtomBIOS headers define no memory type for
+// it.
 var vramType = map[byte]string{
 	0x10: "GDDR1",
 	0x20: "DDR2",
 	0x30: "GDDR3",
 	0x40: "GDDR4",
 	0x50: "GDDR5",
-	0x60: "HBM",
+	0x60: "HBM/HBM2",
+	0x61: "HBM2E",
+	0x70: "GDDR6",
+	0x80: "HBM3",
 	0xB0: "DDR3",
 }
 
 type Bios struct {
+	PCIRHeader PCIRHeader
+	Family string
 	AtomRomHeader AtomRomHeader
 	AtomDataTables AtomDataTables
 	AtomPowerplayTable AtomPowerplayTable
@@ -72,9 +106,43 @@ type Bios struct {
 	AtomMClkTable AtomMClkTable
 	AtomSClkTable AtomSClkTable
 	AtomVoltageTable AtomVoltageTable
+	AtomHardLimitTable AtomHardLimitTable
+	AtomMMDependencyTable AtomMMDependencyTable
+	VoltageObjects []VoltageObject
 	AtomVRAMInfo AtomVRAMInfo
+	AtomVRAMTimingRegisters []AtomInitRegIndex
 	AtomVRAMTimingEntry []AtomVRAMTimingEntry
 	AtomVRAMEntry []AtomVRAMEntry
+	AtomMemAdjustTable AtomInitRegBlock
+	AtomMcAdjustPerTileTable AtomInitRegBlock
+	AtomMcPhyInitTable AtomInitRegBlock
+	AtomDramDataRemapTable AtomDramDataRemapTable
+	MCInitParameter MCInitParameter
+	MemoryTraining MemoryTraining
+	AtomVega10PowerplayTable AtomVega10PowerplayTable
+	AtomVega10GfxClkTable AtomVega10GfxClkTable
+	AtomVega10SocClkTable AtomVega10ClkTable
+	AtomVega10DcefClkTable AtomVega10ClkTable
+	AtomVega10MClkTable AtomVega10MClkTable
+	AtomVega10VddcTable AtomVega10VoltageTable
+	AtomVega10FanTable AtomVega10FanTable
+	AtomVega10PowertuneTable AtomVega10PowertuneTable
+	Tables map[string]TableLocation
+}
+
+type PCIRHeader struct {
+	Signature             [4]byte
+	VendorID              uint16
+	DeviceID              uint16
+	DeviceListOffset      uint16
+	Length                uint16
+	Revision              byte
+	ClassCode             [3]byte
+	ImageLength           uint16
+	CodeRevision          uint16
+	CodeType              byte
+	Indicator             byte
+	MaxRuntimeImageLength uint16
 }
 
 type AtomCommonTableHeader struct {
@@ -185,10 +253,40 @@ type AtomMClkEntry struct {
 
 type AtomMClkTable struct {
 	RevID      byte
-	NumEntries byte
+	NumEntries byte `struct:"sizeof=Entries"`
 	Entries    []AtomMClkEntry
 }
 
+type AtomMMDependencyEntry struct {
+	VddcInd       byte
+	VddgfxOffset  uint16
+	DClk          uint32
+	VClk          uint32
+	EClk          uint32
+	AClk          uint32
+	SAMUClk       uint32
+}
+
+type AtomMMDependencyTable struct {
+	RevID      byte
+	NumEntries byte `struct:"sizeof=Entries"`
+	Entries    []AtomMMDependencyEntry
+}
+
+type AtomHardLimitEntry struct {
+	SclkLimit   uint32
+	MclkLimit   uint32
+	VddcLimit   uint16
+	VddciLimit  uint16
+	VddgfxLimit uint16
+}
+
+type AtomHardLimitTable struct {
+	RevID      byte
+	NumEntries byte `struct:"sizeof=Entries"`
+	Entries    []AtomHardLimitEntry
+}
+
 type AtomSClkEntry struct {
 	VddInd                 byte
 	VddcOffset             uint16
@@ -196,8 +294,7 @@ type AtomSClkEntry struct {
 	EdcCurrent             uint16
 	ReliabilityTemperature byte
 	CKSVOffsetandDisable   byte
-	SclkOffset             uint32
-	// Polaris Only, remove for compatibility with Fiji
+	SclkOffset             uint32 // Polaris only, Tonga and Fiji use AtomTongaSClkEntry
 }
 
 type AtomSClkTable struct {
@@ -206,6 +303,21 @@ type AtomSClkTable struct {
 	Entries    []AtomSClkEntry
 }
 
+type AtomTongaSClkEntry struct {
+	VddInd                 byte
+	VddcOffset             uint16
+	Sclk                   uint32
+	EdcCurrent             uint16
+	ReliabilityTemperature byte
+	CKSVOffsetandDisable   byte
+}
+
+type AtomTongaSClkTable struct {
+	RevID      byte
+	NumEntries byte `struct:"sizeof=Entries"`
+	Entries    []AtomTongaSClkEntry
+}
+
 type AtomVoltageEntry struct {
 	Vdd     uint16
 	CACLow  uint16
@@ -219,6 +331,7 @@ type AtomVoltageTable struct {
 	Entries    []AtomVoltageEntry
 }
 
+// ATOM_Fiji_Fan_Table, RevID 8. Polaris (RevID 9) appends the zero RPM settings.
 type AtomFanTable struct {
 	RevID                   byte
 	THyst                   byte
@@ -246,6 +359,28 @@ type AtomFanTable struct {
 	_                       uint16
 }
 
+type AtomTongaFanTable struct {
+	RevID                   byte
+	THyst                   byte
+	TMin                    uint16
+	TMed                    uint16
+	THigh                   uint16
+	PWMMin                  uint16
+	PWMMed                  uint16
+	PWMHigh                 uint16
+	TMax                    uint16
+	FanControlMode          byte
+	FanPWMMax               uint16
+	FanOutputSensitivity    uint16
+	FanRPMMax               uint16
+	MinFanSCLKAcousticLimit uint32
+	TargetTemperature       byte
+	MinimumPWMLimit         byte
+	_                       uint16
+}
+
+// ATOM_Fiji_PowerTune_Table, RevID 3. Polaris (RevID 4) uses the reserved word
+// for usBoostPowerLimit and appends the CKS and hot spot settings.
 type AtomPowertuneTable struct {
 	RevID                     byte
 	TDP                       uint16
@@ -277,6 +412,26 @@ type AtomPowertuneTable struct {
 	_                         uint16
 }
 
+type AtomTongaPowertuneTable struct {
+	RevID                     byte
+	TDP                       uint16
+	ConfigurableTDP           uint16
+	TDC                       uint16
+	BatteryPowerLimit         uint16
+	SmallPowerLimit           uint16
+	LowCACLeakage             uint16
+	HighCACLeakage            uint16
+	MaximumPowerDeliveryLimit uint16
+	TjMax                     uint16
+	PowerTuneDataSetID        uint16
+	EDCLimit                  uint16
+	SoftwareShutdownTemp      uint16
+	ClockStretchAmount        uint16
+	_                         [2]uint16
+}
+
+// ClkRange holds the highest clock of the strap in 10 kHz in bits 0-23 and
+// the module in bits 24-31.
 type AtomVRAMTimingEntry struct {
 	ClkRange uint32
 	Latency  [0x30]byte
@@ -311,10 +466,77 @@ type AtomVRAMEntry struct {
 	MemPNString       string `struct:"[20]byte"`
 }
 
+// VRAM module layout used by Tonga and Fiji (VRAM info v2.1).
+type AtomVRAMEntryV7 struct {
+	ChannelMapCfg     uint32
+	ModuleSize        uint16
+	_                 uint16
+	EnableChannels    uint16
+	ExtMemoryID       byte
+	MemoryType        byte
+	ChannelNum        byte
+	ChannelWidth      byte
+	Density           byte
+	_                 byte
+	Misc              byte
+	VREFI             byte
+	NPLRT             byte
+	Preamble          byte
+	MemorySize        byte // In units of 16MB.
+	SEQSettingOffset  uint16
+	_                 byte
+	EMRS2Value        uint16
+	EMRS3Value        uint16
+	MemoryVenderID    byte
+	RefreshRateFactor byte
+	FIFODepth         byte
+	CDRBandwidth      byte
+	MemPNString       string `struct:"[20]byte"`
+}
+
+type AtomVoltageObjectHeader struct {
+	VoltageType byte
+	VoltageMode byte
+	Size        uint16
+}
+
+type AtomVoltageLUTEntry struct {
+	VoltageID    uint32
+	VoltageValue uint16
+}
+
+// Used for the GPIO and phase LUT modes.
+type AtomGPIOVoltageObject struct {
+	Header           AtomVoltageObjectHeader
+	VoltageGpioCntlId byte
+	GpioEntryNum     byte `struct:"sizeof=Entries"`
+	PhaseDelay       byte
+	_                byte
+	GpioMaskVal      uint32
+	Entries          []AtomVoltageLUTEntry
+}
+
+type AtomSVID2VoltageObject struct {
+	Header      AtomVoltageObjectHeader
+	LoadLinePSI uint16
+	SVDGpioId   byte
+	SVCGpioId   byte
+	_           uint32
+}
+
+// VoltageObject is a voltage object of VoltageObjectInfo with the voltages
+// of its LUT, if it has one.
+type VoltageObject struct {
+	Header   AtomVoltageObjectHeader
+	Offset   int
+	Voltages []uint16
+}
+
 type AtomVRAMInfo struct {
 	Header                   AtomCommonTableHeader
 	MemAdjustTblOffset       uint16
 	MemClkPatchTblOffset     uint16
+	// usPerBytePresetOffset and reserved words in VRAMInfo 2.1.
 	McAdjustPerTileTblOffset uint16
 	McPhyInitTableOffset     uint16
 	DramDataRemapTblOffset   uint16
@@ -325,3 +547,264 @@ type AtomVRAMInfo struct {
 	McPhyTileNum             byte
 	//VramInfo                 []AtomVRAMEntry
 }
+
+type AtomInitRegBlockHeader struct {
+	RegIndexTblSize uint16
+	RegDataBlkSize  uint16
+}
+
+// PreRegDataLength holds the size of the register data in the low nibble, 0
+// when the register takes the value of the one before it. Registers flagged
+// with AtomRegAccessMCIODebug are MC_IO_DEBUG indexes.
+type AtomInitRegIndex struct {
+	RegIndex         uint16
+	PreRegDataLength byte
+}
+
+// AtomInitRegDataBlock is a data block of a register table with the value of
+// every register, including those that repeat the previous value. MemoryID
+// holds the clock range in 10 kHz in bits 0-23 and the module in bits 24-31.
+type AtomInitRegDataBlock struct {
+	MemoryID uint32
+	Data     []uint32
+}
+
+type AtomInitRegBlock struct {
+	Registers []AtomInitRegIndex
+	Blocks    []AtomInitRegDataBlock
+}
+
+type AtomDramDataRemap struct {
+	ByteRemapCh0Ind byte
+	ByteRemapCh1Ind byte
+	ByteRemapCh0    uint32
+	ByteRemapCh1    uint32
+	BitRemapCh0     [4]uint32
+	BitRemapCh1     [4]uint32
+}
+
+// One remap entry per VRAM module.
+type AtomDramDataRemapTable struct {
+	Entries []AtomDramDataRemap
+}
+
+// The MC init register blocks follow ARBSEQData.
+type AtomMCInitParamTable struct {
+	Header                   AtomCommonTableHeader
+	AdjustARBSEQDataOffset   uint16
+	MCInitMemTypeTblOffset   uint16
+	MCInitCommonTblOffset    uint16
+	MCInitPowerDownTblOffset uint16
+	ARBSEQData               [32]uint32
+}
+
+type AtomMCInitParamTableV21 struct {
+	Header               AtomCommonTableHeader
+	MCUcodeVersion       uint32
+	MCUcodeRomStartAddr  uint32
+	MCUcodeLength        uint32
+	McRegInitTableOffset uint16
+	_                    uint16
+}
+
+type AtomRegInitSetting struct {
+	RegIndex uint16
+	RegValue uint32
+}
+
+type AtomMemoryTrainingInfo struct {
+	Header       AtomCommonTableHeader
+	TrainingLoop byte
+	_            [3]byte
+}
+
+// MCIOInitLen and MCUcodeLen are in bytes.
+type AtomMemoryTrainingInfoV31 struct {
+	Header            AtomCommonTableHeader
+	MCUcodeVersion    uint32
+	MCIOInitLen       uint16
+	MCUcodeLen        uint16
+	MCIORegInitOffset uint16
+	MCUcodeOffset     uint16
+}
+
+// MCInitParameter holds the MCInitParameter table of either revision. V1.1
+// has the register blocks per memory type, V2.1 a list of register settings
+// next to the MC microcode.
+type MCInitParameter struct {
+	ARBSEQData     [32]uint32
+	MemType        AtomInitRegBlock
+	Common         AtomInitRegBlock
+	MCUcodeVersion uint32
+	MCUcodeLength  uint32
+	RegInit        []AtomRegInitSetting
+}
+
+// MemoryTraining holds the MemoryTrainingInfo table of either revision. V2.1
+// has the training settings per module, V3.1 the MC IO init settings that go
+// with the training microcode.
+type MemoryTraining struct {
+	TrainingLoop   byte
+	Settings       AtomInitRegBlock
+	MCUcodeVersion uint32
+	MCUcodeLength  uint16
+	IOInit         []AtomRegInitSetting
+}
+
+type AtomVega10PowerplayTable struct {
+	Header                         AtomCommonTableHeader
+	TableRevision                  byte
+	TableSize                      uint16
+	GoldenPPID                     uint32
+	GoldenRevision                 uint32
+	FormatID                       uint16
+	PlatformCaps                   uint32
+	MaxODEngineClock               uint32
+	MaxODMemoryClock               uint32
+	PowerControlLimit              uint16
+	UlvVoltageOffset               uint16
+	UlvSmnclkDid                   uint16
+	UlvMp1clkDid                   uint16
+	UlvGfxclkBypass                uint16
+	GfxclkSlewRate                 uint16
+	GfxVoltageMode                 byte
+	SocVoltageMode                 byte
+	UclkVoltageMode                byte
+	UvdVoltageMode                 byte
+	VceVoltageMode                 byte
+	Mp0VoltageMode                 byte
+	DcefVoltageMode                byte
+	StateArrayOffset               uint16
+	FanTableOffset                 uint16
+	ThermalControllerOffset        uint16
+	SocclkDependencyTableOffset    uint16
+	MclkDependencyTableOffset      uint16
+	GfxclkDependencyTableOffset    uint16
+	DcefclkDependencyTableOffset   uint16
+	VddcLookupTableOffset          uint16
+	VddmemLookupTableOffset        uint16
+	MMDependencyTableOffset        uint16
+	VCEStateTableOffset            uint16
+	_                              uint16
+	PowerTuneTableOffset           uint16
+	HardLimitTableOffset           uint16
+	VddciLookupTableOffset         uint16
+	PCIETableOffset                uint16
+	PixclkDependencyTableOffset    uint16
+	DispClkDependencyTableOffset   uint16
+	PhyClkDependencyTableOffset    uint16
+}
+
+type AtomVega10GfxClkEntryV1 struct {
+	Clk                  uint32
+	VddInd               byte
+	CKSVOffsetandDisable uint16
+	AVFSOffset           uint16
+}
+
+type AtomVega10GfxClkTableV1 struct {
+	RevID      byte
+	NumEntries byte `struct:"sizeof=Entries"`
+	Entries    []AtomVega10GfxClkEntryV1
+}
+
+type AtomVega10GfxClkEntry struct {
+	Clk                  uint32
+	VddInd               byte
+	CKSVOffsetandDisable uint16
+	AVFSOffset           uint16
+	ACGEnable            byte
+	_                    [3]byte
+}
+
+type AtomVega10GfxClkTable struct {
+	RevID      byte
+	NumEntries byte `struct:"sizeof=Entries"`
+	Entries    []AtomVega10GfxClkEntry
+}
+
+// Used by the socclk and dcefclk dependency tables.
+type AtomVega10ClkEntry struct {
+	Clk    uint32
+	VddInd byte
+}
+
+type AtomVega10ClkTable struct {
+	RevID      byte
+	NumEntries byte `struct:"sizeof=Entries"`
+	Entries    []AtomVega10ClkEntry
+}
+
+type AtomVega10MClkEntry struct {
+	MemClk     uint32
+	VddInd     byte
+	VddMemInd  byte
+	VddciInd   byte
+}
+
+type AtomVega10MClkTable struct {
+	RevID      byte
+	NumEntries byte `struct:"sizeof=Entries"`
+	Entries    []AtomVega10MClkEntry
+}
+
+type AtomVega10VoltageEntry struct {
+	Vdd uint16
+}
+
+type AtomVega10VoltageTable struct {
+	RevID      byte
+	NumEntries byte `struct:"sizeof=Entries"`
+	Entries    []AtomVega10VoltageEntry
+}
+
+// Fan table v2 (RevID 0x0B).
+type AtomVega10FanTable struct {
+	RevID                byte
+	FanOutputSensitivity uint16
+	FanAcousticLimitRpm  uint16
+	ThrottlingRPM        uint16
+	TargetTemperature    uint16
+	MinimumPWMLimit      uint16
+	TargetGfxClk         uint16
+	FanGainEdge          uint16
+	FanGainHotspot       uint16
+	FanGainLiquid        uint16
+	FanGainVrVddc        uint16
+	FanGainVrMvdd        uint16
+	FanGainPlx           uint16
+	FanGainHbm           uint16
+	EnableZeroRPM        byte
+	FanStopTemperature   uint16
+	FanStartTemperature  uint16
+	FanParameters        byte
+	FanMinRPM            byte
+	FanMaxRPM            byte
+}
+
+// Powertune table v2 (RevID 6).
+type AtomVega10PowertuneTable struct {
+	RevID                    byte
+	SocketPowerLimit         uint16
+	BatteryPowerLimit        uint16
+	SmallPowerLimit          uint16
+	TdcLimit                 uint16
+	EdcLimit                 uint16
+	SoftwareShutdownTemp     uint16
+	TemperatureLimitHotSpot  uint16
+	TemperatureLimitLiquid1  uint16
+	TemperatureLimitLiquid2  uint16
+	TemperatureLimitHBM      uint16
+	TemperatureLimitVrSoc    uint16
+	TemperatureLimitVrMem    uint16
+	TemperatureLimitPlx      uint16
+	LoadLineResistance       uint16
+	Liquid1I2CAddress        byte
+	Liquid2I2CAddress        byte
+	LiquidI2CLine            byte
+	VrI2CAddress             byte
+	VrI2CLine                byte
+	PlxI2CAddress            byte
+	PlxI2CLine               byte
+	TemperatureLimitTedge    uint16
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ttacon/chalk"
+)
+
+// The Linux kernel exposes the ROM of a PCI device as
+// /sys/bus/pci/devices/<bdf>/rom. Reading it fails until "1" is written to the
+// file, writing "0" hides it again. A ROM that can be read right away, such as
+// one in a fake sysfs tree, is read without touching its state.
+
+const (
+	PCIVendorAMD        = 0x1002
+	PCIClassDisplay     = 0x03
+	sysfsPCIDevicesPath = "bus/pci/devices"
+)
+
+func pciDevicePath(root string, bdf string) string {
+	return filepath.Join(root, sysfsPCIDevicesPath, filepath.Base(bdf))
+}
+
+// readSysfsValue reads a sysfs attribute holding a single number.
+func readSysfsValue(path string) (uint64, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 0, 64)
+}
+
+// isAMDDisplay tells whether the device is an AMD display controller.
+func isAMDDisplay(root string, bdf string) bool {
+	path := pciDevicePath(root, bdf)
+	vendor, err := readSysfsValue(filepath.Join(path, "vendor"))
+	if err != nil || vendor != PCIVendorAMD {
+		return false
+	}
+	class, err := readSysfsValue(filepath.Join(path, "class"))
+	return err == nil && class>>16 == PCIClassDisplay
+}
+
+// listAMDDisplays returns the addresses of the AMD display devices.
+func listAMDDisplays(root string) ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(root, sysfsPCIDevicesPath))
+	if err != nil {
+		return nil, err
+	}
+	devices := []string{}
+	for _, entry := range entries {
+		if isAMDDisplay(root, entry.Name()) {
+			devices = append(devices, entry.Name())
+		}
+	}
+	return devices, nil
+}
+
+// readDeviceRom reads the ROM of a device through sysfs.
+func readDeviceRom(root string, bdf string) ([]byte, error) {
+	if !isAMDDisplay(root, bdf) {
+		return nil, fmt.Errorf("%s is not an AMD display device", bdf)
+	}
+	rom := filepath.Join(pciDevicePath(root, bdf), "rom")
+	if buffer, err := ioutil.ReadFile(rom); err == nil && len(buffer) > 0 {
+		return buffer, nil
+	}
+
+	if err := writeSysfsValue(rom, "1"); err != nil {
+		return nil, fmt.Errorf("unable to enable the ROM of %s: %s", bdf, err)
+	}
+	buffer, err := ioutil.ReadFile(rom)
+	if disableErr := writeSysfsValue(rom, "0"); disableErr != nil && err == nil {
+		err = fmt.Errorf("unable to disable the ROM of %s: %s", bdf, disableErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(buffer) == 0 {
+		return nil, fmt.Errorf("the ROM of %s is empty", bdf)
+	}
+	return buffer, nil
+}
+
+// writeSysfsValue writes to an attribute without truncating it.
+func writeSysfsValue(path string, value string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write([]byte(value))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func openDevice(root string, bdf string) {
+	buffer, err := readDeviceRom(root, bdf)
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	openRom(buffer)
+}
+
+func openAllDevices(root string) {
+	devices, err := listAMDDisplays(root)
+	if err == nil && len(devices) == 0 {
+		err = fmt.Errorf("no AMD display devices found in %s", filepath.Join(root, sysfsPCIDevicesPath))
+	}
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	for _, device := range devices {
+		fmt.Printf("\n%s========================================%s\n", chalk.Magenta, chalk.Reset)
+		fmt.Printf("%s%s%s%s\n", chalk.Magenta, "Device: ", device, chalk.Reset)
+		fmt.Printf("%s========================================%s\n", chalk.Magenta, chalk.Reset)
+		buffer, err := readDeviceRom(root, device)
+		if err != nil {
+			fmt.Println(chalk.Red, err, chalk.Reset)
+			continue
+		}
+		resetDiagnostics()
+		bios, err := decodeRom(buffer)
+		if err != nil {
+			fmt.Println(chalk.Red, err, chalk.Reset)
+			continue
+		}
+		displayBios(bios)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeSysfs is a sysfs tree in a temporary directory.
+type fakeSysfs struct {
+	root string
+	t    *testing.T
+}
+
+func newFakeSysfs(t *testing.T) *fakeSysfs {
+	root, err := ioutil.TempDir("", "atitool-sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeSysfs{root, t}
+}
+
+func (f *fakeSysfs) write(path string, data string) {
+	path = filepath.Join(f.root, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		f.t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *fakeSysfs) read(path string) string {
+	data, err := ioutil.ReadFile(filepath.Join(f.root, path))
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return string(data)
+}
+
+func (f *fakeSysfs) device(bdf string, vendor string, class string, rom string) {
+	path := filepath.Join(sysfsPCIDevicesPath, bdf)
+	f.write(filepath.Join(path, "vendor"), vendor+"\n")
+	f.write(filepath.Join(path, "class"), class+"\n")
+	f.write(filepath.Join(path, "rom"), rom)
+}
+
+func TestListAMDDisplays(t *testing.T) {
+	f := newFakeSysfs(t)
+	defer os.RemoveAll(f.root)
+	f.device("0000:01:00.0", "0x1002", "0x030000", "rom")
+	f.device("0000:01:00.1", "0x1002", "0x040300", "rom")
+	f.device("0000:02:00.0", "0x10de", "0x030000", "rom")
+	f.device("0000:03:00.0", "0x1002", "0x038000", "rom")
+
+	devices, err := listAMDDisplays(f.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"0000:01:00.0", "0000:03:00.0"}
+	if !reflect.DeepEqual(devices, expected) {
+		t.Errorf("devices are %v, expected %v", devices, expected)
+	}
+	if _, err := readDeviceRom(f.root, "0000:02:00.0"); err == nil {
+		t.Error("the ROM of a device of another vendor was read")
+	}
+}
+
+func TestReadDeviceRom(t *testing.T) {
+	f := newFakeSysfs(t)
+	defer os.RemoveAll(f.root)
+	rom := filepath.Join(sysfsPCIDevicesPath, "0000:01:00.0", "rom")
+
+	// A readable ROM is read without being enabled.
+	f.device("0000:01:00.0", "0x1002", "0x030000", "\x55\xaa")
+	buffer, err := readDeviceRom(f.root, "0000:01:00.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buffer) != "\x55\xaa" || f.read(rom) != "\x55\xaa" {
+		t.Errorf("readable ROM read as %q, left as %q", buffer, f.read(rom))
+	}
+
+	// An empty ROM reads back what enabling wrote and is disabled after.
+	f.write(rom, "")
+	buffer, err = readDeviceRom(f.root, "0000:01:00.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buffer) != "1" {
+		t.Errorf("ROM read as %q after enabling, expected %q", buffer, "1")
+	}
+	if f.read(rom) != "0" {
+		t.Errorf("ROM left as %q, expected %q", f.read(rom), "0")
+	}
+}
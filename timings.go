@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ttacon/chalk"
+)
+
+// ClockRange is a memory clock range in Mhz, both ends included.
+type ClockRange struct {
+	Min uint32
+	Max uint32
+}
+
+// parseClockRanges parses ranges such as 1500-2000 or a single clock such as
+// 1750. Ranges may be given in several flags or separated by commas.
+func parseClockRanges(values []string) ([]ClockRange, error) {
+	ranges := []ClockRange{}
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+			min, err := strconv.ParseUint(bounds[0], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid clock range %s", part)
+			}
+			max := min
+			if len(bounds) == 2 {
+				if max, err = strconv.ParseUint(bounds[1], 10, 32); err != nil || max < min {
+					return nil, fmt.Errorf("invalid clock range %s", part)
+				}
+			}
+			ranges = append(ranges, ClockRange{uint32(min), uint32(max)})
+		}
+	}
+	return ranges, nil
+}
+
+// inClockRanges reports whether a clock is in one of the ranges, no ranges
+// include every clock.
+func inClockRanges(clock uint32, ranges []ClockRange) bool {
+	if len(ranges) == 0 {
+		return true
+	}
+	for _, r := range ranges {
+		if clock >= r.Min && clock <= r.Max {
+			return true
+		}
+	}
+	return false
+}
+
+func strapModule(entry AtomVRAMTimingEntry) uint32 {
+	return entry.ClkRange >> 24
+}
+
+// strapClock returns the highest memory clock of a strap in Mhz.
+func strapClock(entry AtomVRAMTimingEntry) uint32 {
+	return (entry.ClkRange & AtomMemClockRangeMask) / 100
+}
+
+// findStrap returns the index of the strap of a module for a clock, or -1.
+func findStrap(bios Bios, module uint32, clock uint32) int {
+	for i, entry := range bios.AtomVRAMTimingEntry {
+		if strapModule(entry) == module && strapClock(entry) == clock {
+			return i
+		}
+	}
+	return -1
+}
+
+// findModuleByPart returns the module with the part number, or -1.
+func findModuleByPart(bios Bios, partNumber string) int {
+	partNumber = strings.TrimRight(partNumber, "\x00 ")
+	for i, entry := range bios.AtomVRAMEntry {
+		if strings.TrimRight(entry.MemPNString, "\x00 ") == partNumber {
+			return i
+		}
+	}
+	return -1
+}
+
+// compatibleModules checks that the straps of one module suit another, they
+// need the same memory type and chip density.
+func compatibleModules(from AtomVRAMEntry, to AtomVRAMEntry) error {
+	if from.MemoryType != to.MemoryType {
+		return fmt.Errorf("memory types differ, %s straps do not work on %s",
+			displayVramType(from.MemoryType), displayVramType(to.MemoryType))
+	}
+	if from.Density != to.Density {
+		return fmt.Errorf("densities differ, %s straps do not work on %s",
+			displayVramDensity(from.Density), displayVramDensity(to.Density))
+	}
+	return nil
+}
+
+// StrapChange is a strap replaced by a timings command.
+type StrapChange struct {
+	Module  uint32
+	Clock   uint32
+	Changed bool
+}
+
+// sameStrapRegisters reports whether the straps of two bioses hold the same
+// registers in the same order.
+func sameStrapRegisters(a Bios, b Bios) bool {
+	if len(a.AtomVRAMTimingRegisters) != len(b.AtomVRAMTimingRegisters) {
+		return false
+	}
+	for i := range a.AtomVRAMTimingRegisters {
+		if a.AtomVRAMTimingRegisters[i] != b.AtomVRAMTimingRegisters[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// transplantStraps copies the straps of a module of one bios to a module of
+// another, or the same, bios. Only straps with a clock in the ranges are
+// copied and the source needs a strap for the same clock. The straps of both
+// need the same register list.
+func transplantStraps(to *Bios, toModule int, from Bios, fromModule int, ranges []ClockRange) ([]StrapChange, error) {
+	if !sameStrapRegisters(*to, from) {
+		return nil, fmt.Errorf("the straps of the source hold other registers, they can not be copied")
+	}
+	if toModule < 0 || toModule >= len(to.AtomVRAMEntry) {
+		return nil, fmt.Errorf("invalid module %d, the bios has %d modules", toModule, len(to.AtomVRAMEntry))
+	}
+	if fromModule < 0 || fromModule >= len(from.AtomVRAMEntry) {
+		return nil, fmt.Errorf("invalid module %d, the source has %d modules", fromModule, len(from.AtomVRAMEntry))
+	}
+	if err := compatibleModules(from.AtomVRAMEntry[fromModule], to.AtomVRAMEntry[toModule]); err != nil {
+		return nil, err
+	}
+
+	changes := []StrapChange{}
+	for i, entry := range to.AtomVRAMTimingEntry {
+		if strapModule(entry) != uint32(toModule) || !inClockRanges(strapClock(entry), ranges) {
+			continue
+		}
+		source := findStrap(from, uint32(fromModule), strapClock(entry))
+		if source < 0 {
+			return nil, fmt.Errorf("module %d of the source has no strap for %d Mhz", fromModule, strapClock(entry))
+		}
+		latency := from.AtomVRAMTimingEntry[source].Latency
+		changes = append(changes, StrapChange{uint32(toModule), strapClock(entry), latency != entry.Latency})
+		to.AtomVRAMTimingEntry[i].Latency = latency
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("module %d has no straps in the clock ranges", toModule)
+	}
+	return changes, nil
+}
+
+func openStraps(filename string) ([]byte, Bios) {
+	buffer := readFile(filename)
+	if isPowerplayBlob(buffer) {
+		fmt.Println(chalk.Red, filename, "is a PowerPlay table, the timing straps are part of the bios.", chalk.Reset)
+		os.Exit(1)
+	}
+	bios := unpackData(buffer)
+	if _, found := bios.Tables["MemClkPatch"]; !found {
+		fmt.Println(chalk.Red, filename, "has no timing straps that could be decoded.", chalk.Reset)
+		os.Exit(1)
+	}
+	return buffer, bios
+}
+
+// transplantTimings copies straps between modules of a bios. With fromRom the
+// straps come from another bios, from the module with the part number of the
+// target module unless fromModule is given.
+func transplantTimings(filename string, out string, fromModule int, toModule int, rangeFlags []string, fromRom string, force bool) {
+	buffer, bios := openStraps(filename)
+	ranges, err := parseClockRanges(rangeFlags)
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+
+	source := bios
+	if fromRom != "" {
+		_, source = openStraps(fromRom)
+		if fromModule < 0 && toModule >= 0 && toModule < len(bios.AtomVRAMEntry) {
+			partNumber := bios.AtomVRAMEntry[toModule].MemPNString
+			if fromModule = findModuleByPart(source, partNumber); fromModule < 0 {
+				fmt.Println(chalk.Red, fromRom, "has no module with part number", strings.TrimRight(partNumber, "\x00 "), chalk.Reset)
+				os.Exit(1)
+			}
+		}
+	} else if fromModule < 0 {
+		fmt.Println(chalk.Red, "--from-module or --from-rom is required.", chalk.Reset)
+		os.Exit(1)
+	}
+
+	changes, err := transplantStraps(&bios, toModule, source, fromModule, ranges)
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	checkStraps(bios, changes, force)
+	if err := saveTables(out, buffer, &bios, []string{"MemClkPatch"}); err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+
+	from := formatModule(uint32(fromModule), source.AtomVRAMEntry)
+	if fromRom != "" {
+		from += " of " + fromRom
+	}
+	displayStrapChanges(changes, from, bios.AtomVRAMEntry)
+}
+
+// displayStrapChanges shows the straps replaced by the straps of source.
+func displayStrapChanges(changes []StrapChange, source string, modules []AtomVRAMEntry) {
+	for _, change := range changes {
+		result := "copied from " + source
+		if !change.Changed {
+			result = "unchanged, same as " + source
+		}
+		fmt.Printf("%s%s, %d %s: %s%s%s\n", chalk.Bold, formatModule(change.Module, modules), change.Clock, "Mhz",
+			chalk.White, result, chalk.Reset)
+	}
+}
+
+// StrapRedundancy is a strap that normalize found redundant. Clock is the
+// strap, Neighbour the strap after it that has the same timings, or the strap
+// with the same clock range that comes first and hides it.
+type StrapRedundancy struct {
+	Module    uint32
+	Clock     uint32
+	Neighbour uint32
+	Hidden    bool
+}
+
+// normalizeStraps sorts the straps by module and clock and finds the
+// redundant ones: a strap with the timings of the strap after it, which could
+// cover its clocks too, and a strap with the clock range of an earlier strap,
+// which is never used. With merge the redundant straps are removed. It
+// reports whether the order changed.
+func normalizeStraps(bios *Bios, merge bool) (bool, []StrapRedundancy) {
+	straps := bios.AtomVRAMTimingEntry
+	sorted := append([]AtomVRAMTimingEntry{}, straps...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		if strapModule(sorted[a]) != strapModule(sorted[b]) {
+			return strapModule(sorted[a]) < strapModule(sorted[b])
+		}
+		return sorted[a].ClkRange&AtomMemClockRangeMask < sorted[b].ClkRange&AtomMemClockRangeMask
+	})
+	reordered := false
+	for i := range sorted {
+		reordered = reordered || sorted[i] != straps[i]
+	}
+
+	redundant := []StrapRedundancy{}
+	kept := []AtomVRAMTimingEntry{}
+	for i, strap := range sorted {
+		switch {
+		case i > 0 && sorted[i-1].ClkRange == strap.ClkRange:
+			redundant = append(redundant, StrapRedundancy{strapModule(strap), strapClock(strap), strapClock(strap), true})
+			if merge {
+				continue
+			}
+		case i+1 < len(sorted) && strapModule(sorted[i+1]) == strapModule(strap) &&
+			sorted[i+1].ClkRange != strap.ClkRange && sorted[i+1].Latency == strap.Latency:
+			redundant = append(redundant, StrapRedundancy{strapModule(strap), strapClock(strap), strapClock(sorted[i+1]), false})
+			if merge {
+				continue
+			}
+		}
+		kept = append(kept, strap)
+	}
+	bios.AtomVRAMTimingEntry = kept
+	return reordered, redundant
+}
+
+// changedBands returns the straps that cover other clocks after than before.
+// Straps that are never used cover nothing and are left out.
+func changedBands(before []StrapBand, after []StrapBand) []StrapChange {
+	changes := []StrapChange{}
+	for _, band := range after {
+		if band.Hidden {
+			continue
+		}
+		changed := true
+		for _, previous := range before {
+			if !previous.Hidden && previous.Module == band.Module && previous.Max == band.Max {
+				changed = previous.Min != band.Min
+				break
+			}
+		}
+		if changed {
+			changes = append(changes, StrapChange{band.Module, band.Max, true})
+		}
+	}
+	return changes
+}
+
+// normalizeTimings sorts the straps of a bios and reports the redundant ones,
+// with merge they are removed. The table keeps its size. Sorting and merging
+// change which strap serves which clocks, the straps that cover other clocks
+// afterwards are checked before writing.
+func normalizeTimings(filename string, out string, merge bool, force bool) {
+	buffer, bios := openStraps(filename)
+	count := len(bios.AtomVRAMTimingEntry)
+	before := strapBands(bios, nil, nil)
+	reordered, redundant := normalizeStraps(&bios, merge)
+	after := strapBands(bios, nil, nil)
+	changes := changedBands(before, after)
+	checkStraps(bios, changes, force)
+	if err := saveTables(out, buffer, &bios, []string{"MemClkPatch"}); err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+
+	if reordered {
+		fmt.Printf("%s%s%s%d%s\n", chalk.Bold, "Straps sorted by module and clock: ", chalk.White, count, chalk.Reset)
+	} else {
+		fmt.Printf("%s%s%s\n", chalk.Bold, "Straps are in order.", chalk.Reset)
+	}
+	for _, r := range redundant {
+		result := fmt.Sprintf("same timings as the %d Mhz strap", r.Neighbour)
+		if r.Hidden {
+			result = "same clock range as an earlier strap, never used"
+		}
+		if merge {
+			result += ", removed"
+		}
+		fmt.Printf("%s%s, %d %s: %s%s%s\n", chalk.Bold, formatModule(r.Module, bios.AtomVRAMEntry), r.Clock, "Mhz",
+			chalk.White, result, chalk.Reset)
+	}
+	if len(redundant) > 0 && !merge {
+		fmt.Println(chalk.Yellow, "Pass --merge to remove the redundant straps.", chalk.Reset)
+	}
+	if len(changes) > 0 {
+		fmt.Printf("\n%s%s%s\n", chalk.Bold, "Clock bands before:", chalk.Reset)
+		displayStrapBands(changedModuleBands(before, changes), bios.AtomVRAMEntry)
+		fmt.Printf("\n%s%s%s\n", chalk.Bold, "Clock bands after:", chalk.Reset)
+		displayStrapBands(changedModuleBands(after, changes), bios.AtomVRAMEntry)
+	}
+}
+
+// changedModuleBands returns the bands of the modules with changes.
+func changedModuleBands(bands []StrapBand, changes []StrapChange) []StrapBand {
+	changed := []StrapBand{}
+	for _, band := range bands {
+		for _, change := range changes {
+			if change.Module == band.Module {
+				changed = append(changed, band)
+				break
+			}
+		}
+	}
+	return changed
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testStrap is a strap of a module at a clock in Mhz whose first timing byte
+// is timings, straps with the same timings are equal but for the clock.
+func testStrap(module uint32, clock uint32, timings byte) AtomVRAMTimingEntry {
+	entry := AtomVRAMTimingEntry{ClkRange: module<<24 | clock*100}
+	entry.Latency[0] = timings
+	return entry
+}
+
+func TestNormalizeStrapsHidden(t *testing.T) {
+	straps := []AtomVRAMTimingEntry{
+		testStrap(1, 2000, 5),
+		testStrap(0, 1000, 1),
+		testStrap(0, 2000, 2),
+		testStrap(0, 1500, 3),
+		testStrap(0, 2000, 4),
+	}
+	sorted := []AtomVRAMTimingEntry{straps[1], straps[3], straps[2], straps[4], straps[0]}
+	hidden := []StrapRedundancy{{0, 2000, 2000, true}}
+
+	bios := Bios{AtomVRAMTimingEntry: append([]AtomVRAMTimingEntry{}, straps...)}
+	reordered, redundant := normalizeStraps(&bios, false)
+	if !reordered || !reflect.DeepEqual(redundant, hidden) {
+		t.Errorf("reordered %v, redundant %v, expected the second 2000 Mhz strap hidden", reordered, redundant)
+	}
+	if !reflect.DeepEqual(bios.AtomVRAMTimingEntry, sorted) {
+		t.Errorf("straps are %v, expected %v", bios.AtomVRAMTimingEntry, sorted)
+	}
+
+	// The first 2000 Mhz strap in table order is the one in use, it is kept.
+	bios = Bios{AtomVRAMTimingEntry: append([]AtomVRAMTimingEntry{}, straps...)}
+	normalizeStraps(&bios, true)
+	merged := []AtomVRAMTimingEntry{straps[1], straps[3], straps[2], straps[0]}
+	if !reflect.DeepEqual(bios.AtomVRAMTimingEntry, merged) {
+		t.Errorf("merged straps are %v, expected %v", bios.AtomVRAMTimingEntry, merged)
+	}
+}
+
+func TestNormalizeStrapsMergeChain(t *testing.T) {
+	straps := []AtomVRAMTimingEntry{
+		testStrap(0, 1000, 1),
+		testStrap(0, 1250, 1),
+		testStrap(0, 1500, 1),
+		testStrap(0, 2000, 2),
+		testStrap(1, 2000, 2),
+	}
+	bios := Bios{AtomVRAMTimingEntry: append([]AtomVRAMTimingEntry{}, straps...)}
+	before := strapBands(bios, nil, nil)
+	reordered, redundant := normalizeStraps(&bios, true)
+
+	// Each strap of the chain names the strap after it, the last one covers
+	// the clocks of all of them. Straps of another module are no neighbours.
+	chain := []StrapRedundancy{{0, 1000, 1250, false}, {0, 1250, 1500, false}}
+	if reordered || !reflect.DeepEqual(redundant, chain) {
+		t.Errorf("reordered %v, redundant %v, expected %v", reordered, redundant, chain)
+	}
+	kept := []AtomVRAMTimingEntry{straps[2], straps[3], straps[4]}
+	if !reflect.DeepEqual(bios.AtomVRAMTimingEntry, kept) {
+		t.Errorf("merged straps are %v, expected %v", bios.AtomVRAMTimingEntry, kept)
+	}
+	changes := changedBands(before, strapBands(bios, nil, nil))
+	if !reflect.DeepEqual(changes, []StrapChange{{0, 1500, true}}) {
+		t.Errorf("changed bands %v, expected the 1500 Mhz strap", changes)
+	}
+}
+
+func TestChangedBandsSorted(t *testing.T) {
+	// The 1500 Mhz strap is hidden behind the 2000 Mhz strap until sorted,
+	// then it serves 1001-1500 Mhz and the 2000 Mhz strap 1501-2000 Mhz.
+	bios := Bios{AtomVRAMTimingEntry: []AtomVRAMTimingEntry{
+		testStrap(0, 1000, 1),
+		testStrap(0, 2000, 2),
+		testStrap(0, 1500, 3),
+	}}
+	before := strapBands(bios, nil, nil)
+	normalizeStraps(&bios, false)
+	changes := changedBands(before, strapBands(bios, nil, nil))
+	expected := []StrapChange{{0, 1500, true}, {0, 2000, true}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("changed bands %v, expected %v", changes, expected)
+	}
+}
@@ -10,102 +10,100 @@ import (
 )
 
 func unpackData(buffer []byte) Bios {
-	bios := Bios{}
+	bios, err := decodeRom(buffer)
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	return bios
+}
+
+// decodeRom is unpackData that reports errors instead of exiting, a ROM
+// without a header or data table fails and the tables that do not decode are
+// warned about.
+func decodeRom(buffer []byte) (Bios, error) {
+	bios := Bios{Tables: map[string]TableLocation{}}
 
 	// Unpack header.
-	headerOffset := getValueAtPosition(buffer,16, ROM_HEADER_PTR)
+	headerOffset := int(getValueAtPosition(buffer,16, ROM_HEADER_PTR))
 	header := AtomRomHeader{}
-	unpack(buffer, uint16(headerOffset), &header)
+	if err := unpackAt(buffer, headerOffset, &header); err != nil {
+		return bios, fmt.Errorf("Error unpacking the ROM header: %s", err)
+	}
 	bios.AtomRomHeader = header
 
+	// Unpack PCI data structure, it holds the device ID.
+	pcirOffset := int(getValueAtPosition(buffer, 16, AtomROMPCIRPtr))
+	if err := unpackAt(buffer, pcirOffset, &bios.PCIRHeader); err != nil || string(bios.PCIRHeader.Signature[:]) != "PCIR" {
+		fmt.Fprintln(os.Stderr, chalk.Yellow, "No PCI data structure found, the device ID is unknown.", chalk.Reset)
+		bios.PCIRHeader = PCIRHeader{}
+	}
+
 	// Unpack data table.
 	dataTable := AtomDataTables{}
-	unpack(buffer, header.MasterDataTableOffset, &dataTable)
+	if err := unpackAt(buffer, int(header.MasterDataTableOffset), &dataTable); err != nil {
+		return bios, fmt.Errorf("Error unpacking the data table: %s", err)
+	}
 	bios.AtomDataTables = dataTable
 
-	// Unpack powerplay table.
-	powerplayTable := AtomPowerplayTable{}
-	unpack(buffer, dataTable.PowerPlayInfo, &powerplayTable)
-	bios.AtomPowerplayTable = powerplayTable
-
-	// Unpack powertune table.
-	powertuneTable := AtomPowertuneTable{}
-	powertuneOffset := dataTable.PowerPlayInfo + powerplayTable.PowerTuneTableOffset
-	unpack(buffer, powertuneOffset, &powertuneTable)
-	bios.AtomPowertuneTable = powertuneTable
-
-	// Unpack fan table.
-	fanTable := AtomFanTable{}
-	fanTableOffset := dataTable.PowerPlayInfo + powerplayTable.FanTableOffset
-	unpack(buffer, fanTableOffset, &fanTable)
-	bios.AtomFanTable = fanTable
-
-	// Unpack mclk table.
-	mclkTable := AtomMClkTable{}
-	mclkOffset := dataTable.PowerPlayInfo + powerplayTable.VddcLookupTableOffset
-	unpack(buffer, mclkOffset, &mclkTable)
-	bios.AtomMClkTable = mclkTable
-
-	// Unpack sclk table.
-	sclkTable := AtomSClkTable{}
-	sclkOffset := dataTable.PowerPlayInfo + powerplayTable.SclkDependencyTableOffset
-	unpack(buffer, sclkOffset, &sclkTable)
-	bios.AtomSClkTable = sclkTable
-
-	// Unpack voltage table.
-	voltageTable := AtomVoltageTable{}
-	voltageOffset := dataTable.PowerPlayInfo + powerplayTable.VddcLookupTableOffset
-	unpack(buffer, voltageOffset, &voltageTable)
-	bios.AtomVoltageTable = voltageTable
-
-	// Unpack VRAM info.
-	vramInfoOffset := dataTable.VRAMInfo
-	vramInfo := AtomVRAMInfo{}
-	err := restruct.Unpack(buffer[vramInfoOffset:], binary.LittleEndian, &vramInfo)
-	if err != nil {
-		fmt.Println(chalk.Red, "Error unpacking VRAM info: ", err, chalk.Reset)
-		os.Exit(1)
-
+	// Unpack powerplay table and its sub-tables.
+	var err error
+	powerplayOffset := int(dataTable.PowerPlayInfo)
+	if powerplayOffset != 0 {
+		err = decodeTable("PowerPlayInfo", headerRevision(buffer, powerplayOffset), buffer, powerplayOffset, &bios)
+		if err != nil {
+			warnTable(err)
+		}
 	}
-	bios.AtomVRAMInfo = vramInfo
 
-	// HACK: determine sizeof VRAM info.
-	// See restruct issue #5.
-	vramInfoData, err := restruct.Pack(binary.LittleEndian, vramInfo)
-	if err != nil {
-		fmt.Println(chalk.Red, "Error sizing VRAM info: ", err, chalk.Reset)
-		os.Exit(1)
+	// Unpack voltage objects.
+	voltageOffset := int(dataTable.VoltageObjectInfo)
+	if voltageOffset != 0 {
+		err = decodeTable("VoltageObjectInfo", headerRevision(buffer, voltageOffset), buffer, voltageOffset, &bios)
+		if err != nil {
+			warnTable(err)
+		}
 	}
 
-	numberOfVRAMModule := int(vramInfo.NumOfVRAMModule)
-	vramEntryOffset := int(vramInfoOffset) + len(vramInfoData)
-	vramEntries := make([]AtomVRAMEntry, numberOfVRAMModule)
-	for i := 0; i < numberOfVRAMModule; i++ {
-		err := restruct.Unpack(buffer[vramEntryOffset:], binary.LittleEndian, &vramEntries[i])
+	// Unpack VRAM info.
+	vramInfoOffset := int(dataTable.VRAMInfo)
+	if vramInfoOffset != 0 {
+		err = decodeTable("VRAMInfo", headerRevision(buffer, vramInfoOffset), buffer, vramInfoOffset, &bios)
 		if err != nil {
-			fmt.Println(chalk.Red, "Error unpacking VRAM entry: ", err, chalk.Reset)
+			warnTable(err)
 		}
-		vramEntryOffset += int(vramEntries[i].ModuleSize)
 	}
-	bios.AtomVRAMEntry = vramEntries
-
-	// Loop over VRAM timing entries.
-	vramTimingOffset := int(dataTable.VRAMInfo) + len(vramInfoData)
-	vramTimingEntries := make([]AtomVRAMTimingEntry, numberOfVRAMModule)
-	for i := 0; i < AtomMaxVRAMEntries; i++ {
-		vramTimingEntry := AtomVRAMTimingEntry{}
-		err := restruct.Unpack(buffer[vramTimingOffset:], binary.LittleEndian, &vramTimingEntry)
-		if err != nil {
-			fmt.Println(chalk.Red, "Error unpacking timing entry: ", err, chalk.Reset)
-			os.Exit(1)
+
+	// Unpack MC init and memory training parameters.
+	mcTables := []subTable{
+		{"MCInitParameter", dataTable.MCInitParameter},
+		{"MemoryTrainingInfo", dataTable.MemoryTrainingInfo},
+	}
+	for _, mcTable := range mcTables {
+		if mcTable.offset == 0 {
+			continue
 		}
-		if vramTimingEntry.ClkRange == 0 {
-			break
+		offset := int(mcTable.offset)
+		err = decodeTable(mcTable.name, headerRevision(buffer, offset), buffer, offset, &bios)
+		if err != nil {
+			warnTable(err)
 		}
-		vramTimingEntries = append(vramTimingEntries, vramTimingEntry)
-		vramTimingOffset += 0x34
 	}
-	bios.AtomVRAMTimingEntry = vramTimingEntries
+
+	bios.Family = detectFamily(bios)
+	return bios, nil
+}
+
+// unpackPowerplay decodes a bare PowerPlay table, such as the pp_table of the
+// amdgpu driver. Sub-table offsets are relative to the PowerPlay table so they
+// decode the same as in a ROM.
+func unpackPowerplay(buffer []byte) Bios {
+	bios := Bios{Tables: map[string]TableLocation{}}
+	err := decodeTable("PowerPlayInfo", headerRevision(buffer, 0), buffer, 0, &bios)
+	if err != nil {
+		warnTable(err)
+	}
+	bios.Family = detectFamily(bios)
 	return bios
 }
 
@@ -124,7 +122,7 @@ func getValueAtPosition(buffer []byte, bits int32, position int32) int32 {
 		case 8:
 			return int32(buffer[position])
 		case 24:
-			return int32(buffer[position + 2] << 16) | int32(buffer[position + 1] << 8) | int32(buffer[position])
+			return int32(buffer[position + 2]) << 16 | int32(buffer[position + 1]) << 8 | int32(buffer[position])
 		case 16:
 			return int32(binary.LittleEndian.Uint16(buffer[position:]))
 		case 32:
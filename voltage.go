@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ttacon/chalk"
+)
+
+// AtomSVI2MaxVoltage is the highest voltage an SVI2 regulator encodes, VID 0.
+const AtomSVI2MaxVoltage = 1550
+
+// voltageRange returns the voltage range of a regulator from
+// VoltageObjectInfo. LUT regulators are limited to their LUT, SVI2 regulators
+// to what the protocol encodes. The VDDC range is capped by the hard limit.
+func voltageRange(bios Bios, voltageType byte) (uint16, uint16, bool) {
+	for _, object := range bios.VoltageObjects {
+		if object.Header.VoltageType != voltageType {
+			continue
+		}
+		var min, max uint16
+		switch {
+		case len(object.Voltages) > 0:
+			min, max = object.Voltages[0], object.Voltages[0]
+			for _, voltage := range object.Voltages {
+				if voltage < min {
+					min = voltage
+				}
+				if voltage > max {
+					max = voltage
+				}
+			}
+		case object.Header.VoltageMode == VoltageModeSVID2:
+			min, max = 0, AtomSVI2MaxVoltage
+		default:
+			continue
+		}
+		if voltageType == VoltageTypeVDDC && len(bios.AtomHardLimitTable.Entries) > 0 {
+			if limit := bios.AtomHardLimitTable.Entries[0].VddcLimit; limit != 0 && limit < max {
+				max = limit
+			}
+		}
+		return min, max, true
+	}
+	return 0, 0, false
+}
+
+func setVoltage(filename string, out string, levels map[string]string, replaceEVV bool, force bool) {
+	buffer := readFile(filename)
+	bios := openTables(buffer)
+
+	changes, err := setLevelVoltages(&bios, levels, replaceEVV, force)
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	tables := []string{"VddcLookup", "SclkDependency"}
+	reordered, err := sortVoltageLookup(&bios)
+	if err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	if reordered {
+		tables = append(tables, "MclkDependency")
+		if _, found := bios.Tables["MMDependency"]; found {
+			tables = append(tables, "MMDependency")
+		}
+	}
+	if err := saveTables(out, buffer, &bios, tables); err != nil {
+		fmt.Println(chalk.Red, err, chalk.Reset)
+		os.Exit(1)
+	}
+	for _, change := range changes {
+		result := fmt.Sprintf("%s -> %d mV", formatVoltage(change.Previous), change.Voltage)
+		if change.Shared {
+			// The index is read after sorting, the entry may have moved.
+			result += fmt.Sprintf(", shares voltage entry %d and its CAC leakage values", bios.AtomSClkTable.Entries[change.Level].VddInd)
+		}
+		fmt.Printf("%s%s %d: %s%s%s\n", chalk.Bold, "Level", change.Level, chalk.White, result, chalk.Reset)
+	}
+	displayVFCurve(bios)
+}
+
+// VoltageChange is a level voltage changed by voltage set. Shared levels use
+// the lookup entry that had the voltage already, with its CAC leakage values.
+type VoltageChange struct {
+	Level    int
+	Previous uint16
+	Voltage  uint16
+	Shared   bool
+}
+
+// setLevelVoltages gives the GPU clock levels a fixed voltage, given as
+// level=mV pairs. A voltage that is in the lookup table already is shared,
+// otherwise the lookup entry of the level is changed. Entries used by other
+// levels are left alone. Without a VDDC regulator in VoltageObjectInfo the
+// voltages are checked against the hard limit, without either only force
+// sets them.
+func setLevelVoltages(bios *Bios, levels map[string]string, replaceEVV bool, force bool) ([]VoltageChange, error) {
+	if bios.Family == FamilyVega10 {
+		return nil, fmt.Errorf("voltage set supports the v7 voltage lookup table, Vega 10 is not supported")
+	}
+	if len(levels) == 0 {
+		return nil, fmt.Errorf("no levels given, use --level 7=1100mV")
+	}
+	min, max, found := voltageRange(*bios, VoltageTypeVDDC)
+	if !found && len(bios.AtomHardLimitTable.Entries) > 0 && bios.AtomHardLimitTable.Entries[0].VddcLimit != 0 {
+		min, max, found = 0, bios.AtomHardLimitTable.Entries[0].VddcLimit, true
+		fmt.Fprintln(os.Stderr, chalk.Yellow, fmt.Sprintf("No VDDC regulator range in VoltageObjectInfo, the voltages are checked against the hard limit of %d mV.", max), chalk.Reset)
+	}
+	if !found {
+		if !force {
+			return nil, fmt.Errorf("no VDDC regulator range in VoltageObjectInfo and no hard limit, pass --force to set the voltages unchecked")
+		}
+		fmt.Fprintln(os.Stderr, chalk.Yellow, "No VDDC regulator range in VoltageObjectInfo and no hard limit, the voltages are not range checked.", chalk.Reset)
+	}
+
+	changes := []VoltageChange{}
+	keys := make([]string, 0, len(levels))
+	for key := range levels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	lookup := bios.AtomVoltageTable.Entries
+	sclk := bios.AtomSClkTable.Entries
+	for _, key := range keys {
+		level, err := strconv.Atoi(key)
+		if err != nil || level < 0 || level >= len(sclk) {
+			return nil, fmt.Errorf("invalid level %s, the GPU has %d levels", key, len(sclk))
+		}
+		value, err := strconv.ParseUint(strings.TrimSuffix(strings.ToLower(levels[key]), "mv"), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid voltage %s for level %d", levels[key], level)
+		}
+		voltage := uint16(value)
+		if found && (voltage < min || voltage > max) {
+			return nil, fmt.Errorf("%d mV for level %d is outside the VDDC range of %d to %d mV", voltage, level, min, max)
+		}
+		if isVirtualVoltage(voltage) {
+			return nil, fmt.Errorf("%d mV for level %d is a virtual voltage ID", voltage, level)
+		}
+
+		index := int(sclk[level].VddInd)
+		if index >= len(lookup) {
+			return nil, fmt.Errorf("level %d has a voltage lookup index out of range", level)
+		}
+		current := lookup[index].Vdd
+		if isVirtualVoltage(current) && !replaceEVV {
+			return nil, fmt.Errorf("level %d uses the virtual voltage 0x%x, pass --replace-evv to give it a fixed voltage", level, current)
+		}
+
+		shared := -1
+		for i, entry := range lookup {
+			if entry.Vdd == voltage {
+				shared = i
+				break
+			}
+		}
+		switch {
+		case shared >= 0:
+			sclk[level].VddInd = byte(shared)
+		case voltageUsers(*bios, index, level) != "":
+			return nil, fmt.Errorf("voltage entry %d of level %d is also used by %s", index, level, voltageUsers(*bios, index, level))
+		default:
+			lookup[index].Vdd = voltage
+		}
+		changes = append(changes, VoltageChange{level, current, voltage, shared >= 0 && shared != index})
+	}
+	return changes, nil
+}
+
+// voltageUsers lists the levels other than the GPU clock level that use a
+// voltage lookup entry.
+func voltageUsers(bios Bios, index int, level int) string {
+	users := []string{}
+	for i, entry := range bios.AtomSClkTable.Entries {
+		if i != level && int(entry.VddInd) == index {
+			users = append(users, fmt.Sprintf("GPU level %d", i))
+		}
+	}
+	for i, entry := range bios.AtomMClkTable.Entries {
+		if int(entry.VddcInd) == index {
+			users = append(users, fmt.Sprintf("memory level %d", i))
+		}
+	}
+	for i, entry := range bios.AtomMMDependencyTable.Entries {
+		if int(entry.VddcInd) == index {
+			users = append(users, fmt.Sprintf("multimedia level %d", i))
+		}
+	}
+	return strings.Join(users, ", ")
+}
+
+// sortVoltageLookup sorts the voltage lookup table by voltage, the virtual IDs
+// end up last, and moves the indexes of the levels along. It reports whether
+// the order changed.
+func sortVoltageLookup(bios *Bios) (bool, error) {
+	lookup := bios.AtomVoltageTable.Entries
+	order := make([]int, len(lookup))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return lookup[order[a]].Vdd < lookup[order[b]].Vdd
+	})
+	sorted := true
+	for i := range order {
+		sorted = sorted && order[i] == i
+	}
+	if sorted {
+		return false, nil
+	}
+	// Levels of tables that are not decoded would keep their old index.
+	if bios.AtomPowerplayTable.MMDependencyTableOffset != 0 {
+		if _, found := bios.Tables["MMDependency"]; !found {
+			return false, fmt.Errorf("the voltage lookup table needs sorting but the MM dependency table was not decoded")
+		}
+	}
+
+	index := make([]byte, len(lookup))
+	entries := make([]AtomVoltageEntry, len(lookup))
+	for i, old := range order {
+		index[old] = byte(i)
+		entries[i] = lookup[old]
+	}
+	bios.AtomVoltageTable.Entries = entries
+	remap := func(ind *byte) {
+		if int(*ind) < len(index) {
+			*ind = index[*ind]
+		}
+	}
+	for i := range bios.AtomSClkTable.Entries {
+		remap(&bios.AtomSClkTable.Entries[i].VddInd)
+	}
+	for i := range bios.AtomMClkTable.Entries {
+		remap(&bios.AtomMClkTable.Entries[i].VddcInd)
+	}
+	for i := range bios.AtomMMDependencyTable.Entries {
+		remap(&bios.AtomMMDependencyTable.Entries[i].VddcInd)
+	}
+	return true, nil
+}
+
+func formatVoltage(vdd uint16) string {
+	if isVirtualVoltage(vdd) {
+		return fmt.Sprintf("EVV 0x%x", vdd)
+	}
+	return fmt.Sprintf("%d mV", vdd)
+}
+
+// displayVFCurve shows the voltage of every GPU clock level.
+func displayVFCurve(bios Bios) {
+	fmt.Printf("\n%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	fmt.Printf("%s%s%s\n", chalk.Blue, "V/F curve", chalk.Reset)
+	fmt.Printf("%s----------------------------------------%s\n", chalk.Blue, chalk.Reset)
+	for i, level := range sclkLevels(bios) {
+		fmt.Printf("%s%s %d, %d %s: %s%s%s\n", chalk.Bold, "Level", i, level.Clock, "Mhz", chalk.White,
+			formatVoltage(level.VoltageID), chalk.Reset)
+	}
+}